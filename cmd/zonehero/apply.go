@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"gitlab.guerraz.net/HLB/hlb-terraform-provider/hlb"
+)
+
+// errChangesPending is returned by diff --exit-code when live state differs from the manifest
+var errChangesPending = errors.New("live state differs from the manifest")
+
+func init() {
+	// Apply Commands
+	hlbCmd.AddCommand(applyCmd)
+	hlbCmd.AddCommand(diffCmd)
+
+	// Apply Flags
+	applyCmd.Flags().StringP("filename", "f", "", "Manifest describing load balancers and listeners (YAML or JSON)")
+	applyCmd.Flags().Bool("prune", false, "Delete load balancers and listeners that are not declared in the manifest")
+	applyCmd.MarkFlagRequired("filename")
+	addDeleteFlags(applyCmd, false)
+
+	// Diff Flags
+	diffCmd.Flags().StringP("filename", "f", "", "Manifest describing load balancers and listeners (YAML or JSON)")
+	diffCmd.Flags().Bool("prune", false, "Include deletions of load balancers and listeners that are not declared in the manifest")
+	diffCmd.Flags().Bool("exit-code", false, "Exit with status 2 when there are changes")
+	diffCmd.MarkFlagRequired("filename")
+}
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Create, update and delete load balancers to match a manifest",
+	Long: `Compare a manifest of load balancers and listeners with live state, print the plan and apply it.
+Load balancers are matched by name and listeners by port. Resources that are not declared in the
+manifest are left alone unless --prune is set, in which case the listeners of the pruned load
+balancers are deleted with them. Deletions are confirmed before anything is applied, and resources
+with deletion protection are only pruned with --disable-protection. Updates only apply to the version of the resources
+the plan was computed from: a resource changed in the meantime fails the apply with exit code 6,
+run it again to compute a new plan. With --dry-run, the requests that would apply the plan are
+printed instead of being sent, use diff to only print the plan.
+
+Example manifest:
+
+  loadBalancers:
+    - name: web
+      zoneId: Z0123456789ABCDEFGHIJ
+      zoneName: example.com
+      subnets: [subnet-0123456789abcdef0, subnet-0123456789abcdef1]
+      idleTimeout: 120
+      listeners:
+        - port: 443
+          protocol: HTTPS
+          targetGroupArn: arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/web/0123456789abcdef
+          certificateSecretsName: web-certificate`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, plan, err := planFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		if !outputPrinter.isStructured() {
+			writePlan(outputPrinter.out, plan)
+		}
+		if !plan.hasChanges() {
+			if outputPrinter.isStructured() {
+				return outputPrinter.printData(map[string]interface{}{"plan": plan, "applied": false})
+			}
+			return nil
+		}
+
+		opts := deleteOptionsFromFlags(cmd)
+		opts.cascade = true
+		if lbs, listeners := plan.counts(); lbs.Delete > 0 || listeners.Delete > 0 {
+			if err := checkPlanDeletable(plan, opts); err != nil {
+				return err
+			}
+			question := fmt.Sprintf("Delete %d load balancers and %d listeners that are not declared in the manifest?", lbs.Delete, listeners.Delete)
+			if err := confirm(cmd, question); err != nil {
+				return err
+			}
+		}
+
+		fmt.Fprintln(opts.events)
+
+		if err := executePlan(cmd.Context(), client, plan, opts); err != nil {
+			return err
+		}
+
+		summary := "\nApply complete!"
+		if dryRun {
+			summary = "\nDry run complete, nothing was changed. Would have applied:"
+		}
+		lbs, listeners := plan.counts()
+		return outputPrinter.printMessage(map[string]interface{}{"plan": plan, "applied": !dryRun},
+			summary+" Load balancers: %d created, %d updated, %d deleted. Listeners: %d created, %d updated, %d deleted.",
+			lbs.Create, lbs.Update, lbs.Delete, listeners.Create, listeners.Update, listeners.Delete)
+	},
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show the changes apply would make for a manifest",
+	Long: `Compare a manifest of load balancers and listeners with live state and print the plan without
+applying it. Use --exit-code in CI to fail when live state has drifted from the manifest.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		exitCode, _ := cmd.Flags().GetBool("exit-code")
+
+		_, plan, err := planFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		if outputPrinter.isStructured() {
+			if err := outputPrinter.printData(plan); err != nil {
+				return err
+			}
+		} else {
+			writePlan(outputPrinter.out, plan)
+		}
+
+		if exitCode && plan.hasChanges() {
+			return errChangesPending
+		}
+		return nil
+	},
+}
+
+// planFromFlags loads the manifest named by --filename and plans it against live state
+func planFromFlags(cmd *cobra.Command) (*hlb.Client, *applyPlan, error) {
+	filename, _ := cmd.Flags().GetString("filename")
+	prune, _ := cmd.Flags().GetBool("prune")
+
+	m, err := loadManifest(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := createClient(cmd.Context())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	live, err := listAllLoadBalancers(cmd.Context(), client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	plan, err := buildPlan(m, live, liveReader{
+		listListeners: func(loadBalancerID string) ([]hlb.Listener, error) {
+			return listAllListeners(cmd.Context(), client, loadBalancerID)
+		},
+		getLoadBalancer: func(id string) (*hlb.LoadBalancer, error) {
+			return client.GetLoadBalancer(cmd.Context(), id)
+		},
+		getListener: func(loadBalancerID, listenerID string) (*hlb.Listener, error) {
+			return client.GetListener(cmd.Context(), loadBalancerID, listenerID)
+		},
+	}, prune)
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, plan, nil
+}
+
+var planSymbols = map[string]string{
+	planCreate: "+",
+	planUpdate: "~",
+	planDelete: "-",
+	planNoop:   " ",
+}
+
+// writePlan renders plan in a terraform plan like format
+func writePlan(w io.Writer, plan *applyPlan) {
+	if !plan.hasChanges() {
+		fmt.Fprintln(w, "No changes. Live state matches the manifest.")
+		return
+	}
+
+	for _, lb := range plan.LoadBalancers {
+		if lb.Action == planNoop && !hasListenerChanges(lb) {
+			continue
+		}
+		fmt.Fprintf(w, "%s load balancer %s%s\n", planSymbols[lb.Action], lb.Name, formatPlanID(lb.ID))
+		writePlanChanges(w, "      ", lb.Changes)
+		for _, l := range lb.Listeners {
+			if l.Action == planNoop {
+				continue
+			}
+			fmt.Fprintf(w, "    %s listener %d/%s%s\n", planSymbols[l.Action], l.Port, l.Protocol, formatPlanID(l.ID))
+			writePlanChanges(w, "          ", l.Changes)
+		}
+	}
+
+	lbs, listeners := plan.counts()
+	fmt.Fprintf(w, "\nPlan: load balancers %d to create, %d to update, %d to delete; listeners %d to create, %d to update, %d to delete.\n",
+		lbs.Create, lbs.Update, lbs.Delete, listeners.Create, listeners.Update, listeners.Delete)
+}
+
+func writePlanChanges(w io.Writer, indent string, changes []planChange) {
+	for _, c := range changes {
+		fmt.Fprintf(w, "%s%s: %s -> %s\n", indent, c.Field, formatPlanValue(c.From), formatPlanValue(c.To))
+	}
+}
+
+func hasListenerChanges(lb *loadBalancerPlan) bool {
+	for _, l := range lb.Listeners {
+		if l.Action != planNoop {
+			return true
+		}
+	}
+	return false
+}
+
+func formatPlanID(id string) string {
+	if id == "" {
+		return ""
+	}
+	return " (" + id + ")"
+}
+
+func formatPlanValue(value interface{}) string {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(raw)
+}
+
+// checkPlanDeletable fails before anything is applied when deletion protection would make the
+// API reject one of the deletions of plan
+func checkPlanDeletable(plan *applyPlan, opts deleteOptions) error {
+	for _, lbPlan := range plan.LoadBalancers {
+		if lbPlan.Action == planDelete {
+			if err := opts.checkLoadBalancerDeletable(lbPlan.current, lbPlan.currentListeners); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, l := range lbPlan.Listeners {
+			if l.Action != planDelete {
+				continue
+			}
+			if err := opts.checkListenerDeletable(l.current); err != nil {
+				return fmt.Errorf("load balancer %s: %w", lbPlan.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// executePlan applies plan: load balancers are created and updated first along with their
+// listeners, and load balancers planned for deletion are removed last along with their listeners
+func executePlan(ctx context.Context, client *hlb.Client, plan *applyPlan, opts deleteOptions) error {
+	events := opts.events
+	for _, lbPlan := range plan.LoadBalancers {
+		switch lbPlan.Action {
+		case planCreate:
+			fmt.Fprintf(events, "Creating load balancer %s...\n", lbPlan.Name)
+			lb, err := client.CreateLoadBalancer(ctx, lbPlan.desired.createInput())
+			if err != nil {
+				return fmt.Errorf("failed to create load balancer %s: %w", lbPlan.Name, err)
+			}
+			lbPlan.ID = lb.ID
+			fmt.Fprintf(events, "Created load balancer %s (%s)\n", lbPlan.Name, lb.ID)
+		case planUpdate:
+			fmt.Fprintf(events, "Updating load balancer %s (%s)...\n", lbPlan.Name, lbPlan.ID)
+			if _, err := client.UpdateLoadBalancer(ctx, lbPlan.ID, lbPlan.update); err != nil {
+				return fmt.Errorf("failed to update load balancer %s: %w", lbPlan.Name, err)
+			}
+			fmt.Fprintf(events, "Updated load balancer %s (%s)\n", lbPlan.Name, lbPlan.ID)
+		case planDelete:
+			continue
+		}
+
+		if err := executeListenerPlans(ctx, client, lbPlan, opts); err != nil {
+			return err
+		}
+	}
+
+	for _, lbPlan := range plan.LoadBalancers {
+		if lbPlan.Action != planDelete {
+			continue
+		}
+		fmt.Fprintf(events, "Deleting load balancer %s (%s)...\n", lbPlan.Name, lbPlan.ID)
+		if err := opts.deleteLoadBalancer(ctx, client, lbPlan.current, lbPlan.currentListeners); err != nil {
+			return fmt.Errorf("failed to delete load balancer %s: %w", lbPlan.Name, err)
+		}
+		fmt.Fprintf(events, "Deleted load balancer %s (%s)\n", lbPlan.Name, lbPlan.ID)
+	}
+
+	return nil
+}
+
+func executeListenerPlans(ctx context.Context, client *hlb.Client, lbPlan *loadBalancerPlan, opts deleteOptions) error {
+	events := opts.events
+	for _, l := range lbPlan.Listeners {
+		switch l.Action {
+		case planCreate:
+			listener, err := client.CreateListener(ctx, lbPlan.ID, l.desired.createInput())
+			if err != nil {
+				return fmt.Errorf("failed to create listener %d on load balancer %s: %w", l.Port, lbPlan.Name, err)
+			}
+			l.ID = listener.ID
+			fmt.Fprintf(events, "Created listener %d/%s (%s) on %s\n", l.Port, l.Protocol, listener.ID, lbPlan.Name)
+		case planUpdate:
+			if _, err := client.UpdateListener(ctx, lbPlan.ID, l.ID, l.update); err != nil {
+				return fmt.Errorf("failed to update listener %d on load balancer %s: %w", l.Port, lbPlan.Name, err)
+			}
+			fmt.Fprintf(events, "Updated listener %d/%s (%s) on %s\n", l.Port, l.Protocol, l.ID, lbPlan.Name)
+		case planDelete:
+			l.current.LoadBalancerID = lbPlan.ID
+			if err := opts.deleteListener(ctx, client, l.current); err != nil {
+				return fmt.Errorf("failed to delete listener %d on load balancer %s: %w", l.Port, lbPlan.Name, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gitlab.guerraz.net/HLB/hlb-terraform-provider/hlb"
+)
+
+// backupVersion is the version of the backup document format written by backup. Restore
+// rejects documents with a newer version.
+const backupVersion = 1
+
+func init() {
+	// Backup Commands
+	hlbCmd.AddCommand(backupCmd)
+	hlbCmd.AddCommand(restoreCmd)
+
+	// Backup Flags
+	backupCmd.Flags().String("out", "", "File in which to write the backup (default: print to stdout)")
+
+	// Restore Flags
+	restoreCmd.Flags().String("in", "", "Backup file to restore")
+	restoreCmd.Flags().StringArray("map-subnet", []string{}, "Replace a subnet of the backup in the format old=new (can be repeated)")
+	restoreCmd.Flags().StringArray("map-security-group", []string{}, "Replace a security group of the backup in the format old=new (can be repeated)")
+	restoreCmd.Flags().StringArray("map-target-group", []string{}, "Replace a target group ARN of the backup in the format old=new (can be repeated)")
+	restoreCmd.MarkFlagRequired("in")
+}
+
+// backupDocument is the versioned snapshot of every load balancer and listener of an account
+type backupDocument struct {
+	Version       int                  `json:"version"`
+	CreatedAt     time.Time            `json:"createdAt"`
+	AccountID     string               `json:"accountId"`
+	Region        string               `json:"region"`
+	Partition     string               `json:"partition"`
+	LoadBalancers []backupLoadBalancer `json:"loadBalancers"`
+}
+
+type backupLoadBalancer struct {
+	hlb.LoadBalancer
+	Listeners []hlb.Listener `json:"listeners"`
+}
+
+// backupMappings replaces account and region specific identifiers when restoring
+type backupMappings struct {
+	subnets        map[string]string
+	securityGroups map[string]string
+	targetGroups   map[string]string
+	// strict requires every identifier to be mapped, as identifiers of another account or
+	// region cannot be used as is
+	strict bool
+}
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Back up every load balancer and listener to a file",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out, _ := cmd.Flags().GetString("out")
+
+		client, err := createClient(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		loadBalancers, err := listAllLoadBalancers(cmd.Context(), client)
+		if err != nil {
+			return err
+		}
+
+		doc := backupDocument{
+			Version:   backupVersion,
+			CreatedAt: time.Now().UTC(),
+			AccountID: client.GetAccountID(),
+			Region:    client.GetRegion(),
+			Partition: client.GetPartition(),
+		}
+		listenerCount := 0
+		for i := range loadBalancers {
+			lb := &loadBalancers[i]
+			if !isLiveLoadBalancerState(lb.State) {
+				continue
+			}
+			listeners, err := listAllListeners(cmd.Context(), client, lb.ID)
+			if err != nil {
+				return err
+			}
+			doc.LoadBalancers = append(doc.LoadBalancers, backupLoadBalancer{LoadBalancer: *lb, Listeners: listeners})
+			listenerCount += len(listeners)
+		}
+
+		if out == "" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(doc)
+		}
+
+		data, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode backup: %w", err)
+		}
+		if err := os.WriteFile(out, append(data, '\n'), 0600); err != nil {
+			return fmt.Errorf("failed to write backup: %w", err)
+		}
+
+		return outputPrinter.printMessage(map[string]interface{}{
+			"file":          out,
+			"loadBalancers": len(doc.LoadBalancers),
+			"listeners":     listenerCount,
+		}, "Backed up %d load balancers and %d listeners to %s", len(doc.LoadBalancers), listenerCount, out)
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Recreate load balancers and listeners from a backup",
+	Long: `Recreate the load balancers and listeners of a backup file. Load balancers that already exist
+with the same name are skipped.
+
+The backup can be restored into another region or account by selecting it with the global --region
+and --profile flags. Subnets, security groups and target groups are specific to a region and account,
+so every one of them must then be remapped with --map-subnet, --map-security-group and
+--map-target-group.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		in, _ := cmd.Flags().GetString("in")
+
+		doc, err := loadBackup(in)
+		if err != nil {
+			return err
+		}
+
+		mappings := backupMappings{}
+		for _, m := range []struct {
+			flag   string
+			target *map[string]string
+		}{
+			{"map-subnet", &mappings.subnets},
+			{"map-security-group", &mappings.securityGroups},
+			{"map-target-group", &mappings.targetGroups},
+		} {
+			values, _ := cmd.Flags().GetStringArray(m.flag)
+			if *m.target, err = parseMappings(m.flag, values); err != nil {
+				return err
+			}
+		}
+
+		client, err := createClient(cmd.Context())
+		if err != nil {
+			return err
+		}
+		mappings.strict = client.GetRegion() != doc.Region || client.GetAccountID() != doc.AccountID
+
+		// Map and validate everything before creating anything
+		inputs := make([]*hlb.LoadBalancerCreate, len(doc.LoadBalancers))
+		listenerInputs := make([][]*hlb.ListenerCreate, len(doc.LoadBalancers))
+		for i := range doc.LoadBalancers {
+			if inputs[i], listenerInputs[i], err = mappings.apply(&doc.LoadBalancers[i]); err != nil {
+				return err
+			}
+		}
+
+		live, err := listAllLoadBalancers(cmd.Context(), client)
+		if err != nil {
+			return err
+		}
+		existing := map[string]bool{}
+		for _, lb := range live {
+			if isLiveLoadBalancerState(lb.State) {
+				existing[lb.Name] = true
+			}
+		}
+
+		events := outputPrinter.events()
+
+		var restored, skipped []string
+		for i, input := range inputs {
+			if existing[input.Name] {
+				fmt.Fprintf(events, "Skipped load balancer %s: a load balancer with this name already exists\n", input.Name)
+				skipped = append(skipped, input.Name)
+				continue
+			}
+
+			fmt.Fprintf(events, "Creating load balancer %s...\n", input.Name)
+			lb, err := client.CreateLoadBalancer(cmd.Context(), input)
+			if err != nil {
+				return fmt.Errorf("failed to restore load balancer %s: %w", input.Name, err)
+			}
+			for _, listenerInput := range listenerInputs[i] {
+				listener, err := client.CreateListener(cmd.Context(), lb.ID, listenerInput)
+				if err != nil {
+					return fmt.Errorf("failed to restore listener %d of load balancer %s: %w", listenerInput.Port, input.Name, err)
+				}
+				fmt.Fprintf(events, "Created listener %d/%s (%s) on %s\n", listener.Port, listener.Protocol, listener.ID, input.Name)
+			}
+			fmt.Fprintf(events, "Restored load balancer %s (%s)\n", input.Name, lb.ID)
+			restored = append(restored, input.Name)
+		}
+
+		return outputPrinter.printMessage(map[string]interface{}{
+			"restored": restored,
+			"skipped":  skipped,
+		}, "Restored %d load balancers, skipped %d that already exist", len(restored), len(skipped))
+	},
+}
+
+func loadBackup(path string) (*backupDocument, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup: %w", err)
+	}
+
+	var doc backupDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse backup %s: %w", path, err)
+	}
+	if doc.Version == 0 {
+		return nil, fmt.Errorf("%s is not a backup file: missing version", path)
+	}
+	if doc.Version > backupVersion {
+		return nil, fmt.Errorf("backup %s has version %d, this version of zonehero supports up to version %d", path, doc.Version, backupVersion)
+	}
+	return &doc, nil
+}
+
+// parseMappings parses repeated old=new flags
+func parseMappings(flag string, values []string) (map[string]string, error) {
+	mappings := make(map[string]string, len(values))
+	for _, v := range values {
+		from, to, ok := strings.Cut(v, "=")
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid %s %q: must be in the format old=new", flag, v)
+		}
+		mappings[from] = to
+	}
+	return mappings, nil
+}
+
+// apply returns the requests recreating lb and its listeners with identifiers remapped
+func (m *backupMappings) apply(lb *backupLoadBalancer) (*hlb.LoadBalancerCreate, []*hlb.ListenerCreate, error) {
+	input := loadBalancerCreateFromLive(&lb.LoadBalancer)
+
+	var err error
+	if input.Subnets, err = m.mapAll("subnet", "map-subnet", m.subnets, lb.Subnets); err != nil {
+		return nil, nil, fmt.Errorf("load balancer %s: %w", lb.Name, err)
+	}
+	if input.SecurityGroups, err = m.mapAll("security group", "map-security-group", m.securityGroups, lb.SecurityGroups); err != nil {
+		return nil, nil, fmt.Errorf("load balancer %s: %w", lb.Name, err)
+	}
+	if err := validateLoadBalancerCreate(input); err != nil {
+		return nil, nil, fmt.Errorf("load balancer %s: %w", lb.Name, err)
+	}
+
+	listeners := make([]*hlb.ListenerCreate, 0, len(lb.Listeners))
+	for i := range lb.Listeners {
+		listener := listenerCreateFromLive(&lb.Listeners[i])
+		targetGroups, err := m.mapAll("target group", "map-target-group", m.targetGroups, []string{listener.TargetGroupARN})
+		if err != nil {
+			return nil, nil, fmt.Errorf("load balancer %s listener %d: %w", lb.Name, listener.Port, err)
+		}
+		listener.TargetGroupARN = targetGroups[0]
+		listeners = append(listeners, listener)
+	}
+
+	return input, listeners, nil
+}
+
+func (m *backupMappings) mapAll(kind, flag string, mappings map[string]string, values []string) ([]string, error) {
+	mapped := make([]string, 0, len(values))
+	for _, v := range values {
+		if to, ok := mappings[v]; ok {
+			mapped = append(mapped, to)
+			continue
+		}
+		if m.strict {
+			return nil, fmt.Errorf("%s %s belongs to the account or region of the backup, map it with --%s %s=...", kind, v, flag, v)
+		}
+		mapped = append(mapped, v)
+	}
+	return mapped, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"gitlab.guerraz.net/HLB/hlb-terraform-provider/hlb"
+)
+
+func init() {
+	// Clone Commands
+	hlbCmd.AddCommand(cloneCmd)
+
+	// Clone Flags
+	cloneCmd.Flags().String("id", "", "ID of the load balancer to clone")
+	cloneCmd.Flags().String("name", "", "Name of the new load balancer")
+	cloneCmd.Flags().StringSlice("subnets", []string{}, "Subnets for the new load balancer (default: the subnets of the source)")
+	cloneCmd.Flags().StringSlice("security-groups", []string{}, "Security groups for the new load balancer (default: the security groups of the source)")
+	cloneCmd.Flags().Bool("with-listeners", false, "Recreate the listeners of the source on the new load balancer")
+	cloneCmd.Flags().StringArray("map-target-group", []string{}, "Send traffic of listeners using a target group to another one, in the format old=new (can be repeated)")
+	cloneCmd.MarkFlagRequired("id")
+	cloneCmd.MarkFlagRequired("name")
+}
+
+var cloneCmd = &cobra.Command{
+	Use:   "clone",
+	Short: "Create a copy of a load balancer, optionally with its listeners",
+	Long: `Create a new load balancer with every setting of an existing one, for example to run a blue/green
+twin of a production load balancer. Subnets and security groups can be overridden, and with
+--with-listeners the listeners of the source are recreated against the same target groups unless
+they are remapped with --map-target-group.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, _ := cmd.Flags().GetString("id")
+		name, _ := cmd.Flags().GetString("name")
+		withListeners, _ := cmd.Flags().GetBool("with-listeners")
+		mapTargetGroups, _ := cmd.Flags().GetStringArray("map-target-group")
+
+		targetGroups, err := parseMappings("map-target-group", mapTargetGroups)
+		if err != nil {
+			return err
+		}
+		if len(targetGroups) > 0 && !withListeners {
+			return fmt.Errorf("--map-target-group requires --with-listeners")
+		}
+
+		client, err := createClient(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		source, err := client.GetLoadBalancer(cmd.Context(), id)
+		if err != nil {
+			return err
+		}
+
+		input := loadBalancerCreateFromLive(source)
+		input.Name = name
+		if cmd.Flags().Changed("subnets") {
+			input.Subnets, _ = cmd.Flags().GetStringSlice("subnets")
+		}
+		if cmd.Flags().Changed("security-groups") {
+			input.SecurityGroups, _ = cmd.Flags().GetStringSlice("security-groups")
+		}
+		if err := validateLoadBalancerCreate(input); err != nil {
+			return err
+		}
+
+		// Read the listeners before creating anything so that a failure leaves nothing behind
+		var listenerInputs []*hlb.ListenerCreate
+		if withListeners {
+			listeners, err := listAllListeners(cmd.Context(), client, source.ID)
+			if err != nil {
+				return err
+			}
+			for i := range listeners {
+				listenerInput := listenerCreateFromLive(&listeners[i])
+				if to, ok := targetGroups[listenerInput.TargetGroupARN]; ok {
+					listenerInput.TargetGroupARN = to
+				}
+				listenerInputs = append(listenerInputs, listenerInput)
+			}
+		}
+
+		events := outputPrinter.events()
+		fmt.Fprintf(events, "Creating load balancer %s from %s (%s)...\n", name, source.Name, source.ID)
+		lb, err := client.CreateLoadBalancer(cmd.Context(), input)
+		if err != nil {
+			return err
+		}
+
+		for _, listenerInput := range listenerInputs {
+			listener, err := client.CreateListener(cmd.Context(), lb.ID, listenerInput)
+			if err != nil {
+				return fmt.Errorf("load balancer %s was created but cloning listener %d failed: %w", lb.ID, listenerInput.Port, err)
+			}
+			fmt.Fprintf(events, "Created listener %d/%s (%s)\n", listener.Port, listener.Protocol, listener.ID)
+		}
+
+		return outputPrinter.printObject(lb, describeLoadBalancer(lb))
+	},
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gitlab.guerraz.net/HLB/hlb-terraform-provider/hlb"
+)
+
+// completionCacheTTL is how long completion candidates fetched from the API are reused, so that
+// pressing tab repeatedly does not list every load balancer each time
+const completionCacheTTL = time.Minute
+
+// completionCandidate is an ID offered by shell completion, with a description shown by the shells
+// that support it
+type completionCandidate struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+}
+
+type completionCache struct {
+	FetchedAt  time.Time             `json:"fetchedAt"`
+	Candidates []completionCandidate `json:"candidates"`
+}
+
+// registerIDCompletions completes the load balancer and listener ID flags of cmd and of its
+// subcommands from the API
+func registerIDCompletions(cmd *cobra.Command) {
+	for flag, complete := range map[string]cobra.CompletionFunc{
+		"id":               completeLoadBalancerIDs,
+		"load-balancer-id": completeLoadBalancerIDs,
+		"listener-id":      completeListenerIDs,
+	} {
+		if cmd.Flags().Lookup(flag) != nil {
+			cmd.RegisterFlagCompletionFunc(flag, complete)
+		}
+	}
+	for _, sub := range cmd.Commands() {
+		registerIDCompletions(sub)
+	}
+}
+
+func completeLoadBalancerIDs(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	candidates, err := cachedCompletions(cmd, "load-balancers", func(ctx context.Context, client *hlb.Client) ([]completionCandidate, error) {
+		loadBalancers, err := listAllLoadBalancers(ctx, client)
+		if err != nil {
+			return nil, err
+		}
+
+		candidates := []completionCandidate{}
+		for _, lb := range loadBalancers {
+			if isLiveLoadBalancerState(lb.State) {
+				candidates = append(candidates, completionCandidate{ID: lb.ID, Description: lb.Name})
+			}
+		}
+		return candidates, nil
+	})
+	return completions(candidates, toComplete, err)
+}
+
+func completeListenerIDs(cmd *cobra.Command, args []string, toComplete string) ([]cobra.Completion, cobra.ShellCompDirective) {
+	lbID, _ := cmd.Flags().GetString("load-balancer-id")
+	if lbID == "" {
+		cobra.CompDebugln("--load-balancer-id is required to complete listener IDs", false)
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	candidates, err := cachedCompletions(cmd, "listeners/"+lbID, func(ctx context.Context, client *hlb.Client) ([]completionCandidate, error) {
+		listeners, err := listAllListeners(ctx, client, lbID)
+		if err != nil {
+			return nil, err
+		}
+
+		candidates := []completionCandidate{}
+		for _, l := range listeners {
+			candidates = append(candidates, completionCandidate{ID: l.ID, Description: fmt.Sprintf("%d/%s", l.Port, l.Protocol)})
+		}
+		return candidates, nil
+	})
+	return completions(candidates, toComplete, err)
+}
+
+func completions(candidates []completionCandidate, toComplete string, err error) ([]cobra.Completion, cobra.ShellCompDirective) {
+	if err != nil {
+		cobra.CompErrorln(err.Error())
+		return nil, cobra.ShellCompDirectiveError
+	}
+
+	var matches []cobra.Completion
+	for _, c := range candidates {
+		if strings.HasPrefix(c.ID, toComplete) {
+			matches = append(matches, cobra.CompletionWithDesc(c.ID, c.Description))
+		}
+	}
+	return matches, cobra.ShellCompDirectiveNoFileComp
+}
+
+// cachedCompletions returns the candidates cached under key for the current API key, context and
+// region, calling fetch when they are missing or older than completionCacheTTL
+func cachedCompletions(cmd *cobra.Command, key string, fetch func(ctx context.Context, client *hlb.Client) ([]completionCandidate, error)) ([]completionCandidate, error) {
+	// Completions run without the persistent pre-run hooks of the command
+	if err := applyContext(cmd); err != nil {
+		return nil, err
+	}
+	key, err := completionCacheKey(key)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(getCompletionCacheDir(), key+".json")
+
+	var cache completionCache
+	if data, err := os.ReadFile(path); err == nil && json.Unmarshal(data, &cache) == nil && time.Since(cache.FetchedAt) < completionCacheTTL {
+		return cache.Candidates, nil
+	}
+
+	client, err := createClient(cmd.Context())
+	if err != nil {
+		return nil, err
+	}
+	candidates, err := fetch(cmd.Context(), client)
+	if err != nil {
+		return nil, err
+	}
+
+	// The cache only speeds completions up, failing to write it is not an error
+	if data, err := json.Marshal(completionCache{FetchedAt: time.Now(), Candidates: candidates}); err == nil {
+		if os.MkdirAll(filepath.Dir(path), 0700) == nil {
+			os.WriteFile(path, data, 0600)
+		}
+	}
+	return candidates, nil
+}
+
+// completionCacheKey derives the name of a cache entry from everything that selects the account
+// and region the candidates come from, without writing the API key to disk
+func completionCacheKey(key string) (string, error) {
+	apiKey, err := resolveAPIKey()
+	if err != nil {
+		return "", err
+	}
+
+	endpoint := ""
+	if activeContext != nil {
+		endpoint = activeContext.Endpoint
+	}
+	h := sha256.New()
+	for _, part := range []string{apiKey, profile, os.Getenv("AWS_PROFILE"), region, os.Getenv("AWS_REGION"), partition, endpoint, key} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func getCompletionCacheDir() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".hlb", "cache", "completion")
+}
@@ -0,0 +1,477 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gitlab.guerraz.net/HLB/hlb-terraform-provider/hlb"
+	"gopkg.in/ini.v1"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	configFormatYAML = "yaml"
+	configFormatINI  = "ini"
+
+	// iniCurrentContextKey holds the current context in the default section of INI config files
+	iniCurrentContextKey = "current-context"
+)
+
+// contextFields are the settings a context can hold, in the order they are displayed
+var contextFields = []string{"api-key", "api-key-env", "api-key-command", "profile", "region", "partition", "endpoint", "output"}
+
+// cliConfig is the content of ~/.hlb/config. It is read and written as YAML, or as INI with one
+// section per context.
+type cliConfig struct {
+	CurrentContext string                    `yaml:"current-context,omitempty"`
+	Contexts       map[string]*configContext `yaml:"contexts,omitempty"`
+
+	path   string
+	format string
+}
+
+// configContext bundles the settings of one environment, selected with --context, HLB_CONTEXT or
+// the current context of the config file
+type configContext struct {
+	// The API key is read from the first of APIKey, APIKeyEnv and APIKeyCommand that is set
+	APIKey        string `yaml:"api-key,omitempty" json:"apiKey,omitempty"`
+	APIKeyEnv     string `yaml:"api-key-env,omitempty" json:"apiKeyEnv,omitempty"`
+	APIKeyCommand string `yaml:"api-key-command,omitempty" json:"apiKeyCommand,omitempty"`
+	Profile       string `yaml:"profile,omitempty" json:"profile,omitempty"`
+	Region        string `yaml:"region,omitempty" json:"region,omitempty"`
+	Partition     string `yaml:"partition,omitempty" json:"partition,omitempty"`
+	Endpoint      string `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+	Output        string `yaml:"output,omitempty" json:"output,omitempty"`
+}
+
+var (
+	contextName string
+
+	// activeContext is the context selected for the running command, nil if there is none
+	activeContext *configContext
+)
+
+func init() {
+	// Config Commands
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(getContextsCmd)
+	configCmd.AddCommand(useContextCmd)
+	configCmd.AddCommand(setConfigCmd)
+
+	// Global Flags
+	rootCmd.PersistentFlags().StringVar(&contextName, "context", "", "Context of ~/.hlb/config to use (default: $HLB_CONTEXT, then the current context)")
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the contexts of ~/.hlb/config",
+	Long: `Manage the contexts of ~/.hlb/config. A context bundles the API key source, AWS profile, region,
+partition, endpoint and default output format of an environment, so that they do not need to be
+passed on every invocation.
+
+The context used is the one named by --context, then $HLB_CONTEXT, then the current context of the
+file. Flags and the HLB_API_KEY environment variable take precedence over the settings of the context.
+
+The file is YAML:
+
+  current-context: prod
+  contexts:
+    prod:
+      api-key-env: HLB_PROD_API_KEY
+      profile: prod
+      region: eu-west-1
+      output: json
+
+or INI, with one section per context:
+
+  current-context = prod
+
+  [prod]
+  api-key-command = pass show hlb/prod
+  profile = prod
+  region = eu-west-1`,
+	// Contexts are managed here rather than applied, so that a broken one can be fixed
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		var err error
+		outputPrinter, err = newPrinter()
+		return err
+	},
+}
+
+var getContextsCmd = &cobra.Command{
+	Use:   "get-contexts",
+	Short: "List the contexts",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		type contextItem struct {
+			Name          string `json:"name" yaml:"name"`
+			Current       bool   `json:"current" yaml:"current"`
+			configContext `yaml:",inline"`
+		}
+
+		current := cfg.selectedContextName()
+		items := []contextItem{}
+		t := &table{headers: []string{"CURRENT", "NAME", "PROFILE", "REGION", "PARTITION", "OUTPUT"}, wideHeaders: []string{"API KEY", "ENDPOINT"}}
+		for _, name := range cfg.contextNames() {
+			ctx := cfg.Contexts[name]
+			redacted := *ctx
+			redacted.APIKey = hlb.RedactSecret(redacted.APIKey)
+			items = append(items, contextItem{Name: name, Current: name == current, configContext: redacted})
+
+			marker := ""
+			if name == current {
+				marker = "*"
+			}
+			t.rows = append(t.rows, []string{marker, name, orNone(ctx.Profile), orNone(ctx.Region), orNone(ctx.Partition), orNone(ctx.Output)})
+			t.wideRows = append(t.wideRows, []string{orNone(ctx.apiKeySource()), orNone(ctx.Endpoint)})
+		}
+		return outputPrinter.printList(map[string]interface{}{"items": items}, t)
+	},
+}
+
+var useContextCmd = &cobra.Command{
+	Use:   "use-context NAME",
+	Short: "Set the current context",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		if _, ok := cfg.Contexts[args[0]]; !ok {
+			return fmt.Errorf("context %q not found in %s", args[0], cfg.path)
+		}
+
+		cfg.CurrentContext = args[0]
+		if err := cfg.save(); err != nil {
+			return err
+		}
+		return outputPrinter.printMessage(map[string]string{"currentContext": args[0]}, "Switched to context %q", args[0])
+	},
+}
+
+var setConfigCmd = &cobra.Command{
+	Use:   "set FIELD VALUE",
+	Short: "Set a field of a context, creating the context if needed",
+	Long: `Set a field of the context selected with --context, $HLB_CONTEXT or the current context. The context
+is created if it does not exist, and becomes the current context if there is none. An empty value
+removes the field.
+
+Fields: ` + strings.Join(contextFields, ", "),
+	Args: cobra.ExactArgs(2),
+	ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return contextFields, cobra.ShellCompDirectiveNoFileComp
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		field, value := args[0], args[1]
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		name := cfg.selectedContextName()
+		if name == "" {
+			return fmt.Errorf("no context selected, name the context to set with --context")
+		}
+
+		ctx, ok := cfg.Contexts[name]
+		if !ok {
+			ctx = &configContext{}
+			cfg.Contexts[name] = ctx
+		}
+		target := ctx.field(field)
+		if target == nil {
+			return fmt.Errorf("unknown field %q: must be one of %s", field, strings.Join(contextFields, ", "))
+		}
+		*target = value
+		if err := ctx.validate(); err != nil {
+			return fmt.Errorf("invalid context %q: %w", name, err)
+		}
+		if cfg.CurrentContext == "" {
+			cfg.CurrentContext = name
+		}
+
+		if err := cfg.save(); err != nil {
+			return err
+		}
+		return outputPrinter.printMessage(map[string]string{"context": name, "field": field}, "Set %s of context %q", field, name)
+	},
+}
+
+func getConfigPath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".hlb", "config")
+}
+
+// loadConfig reads ~/.hlb/config. A missing file is an empty configuration.
+func loadConfig() (*cliConfig, error) {
+	cfg := &cliConfig{path: getConfigPath(), format: configFormatYAML, Contexts: map[string]*configContext{}}
+
+	data, err := os.ReadFile(cfg.path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	if isINIConfig(data) {
+		cfg.format = configFormatINI
+		err = cfg.unmarshalINI(data)
+	} else {
+		err = yaml.Unmarshal(data, cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", cfg.path, err)
+	}
+	if cfg.Contexts == nil {
+		cfg.Contexts = map[string]*configContext{}
+	}
+
+	for _, name := range cfg.contextNames() {
+		if cfg.Contexts[name] == nil {
+			cfg.Contexts[name] = &configContext{}
+		}
+		if err := cfg.Contexts[name].validate(); err != nil {
+			return nil, fmt.Errorf("invalid context %q in %s: %w", name, cfg.path, err)
+		}
+	}
+	return cfg, nil
+}
+
+// isINIConfig reports whether the first setting of a config file is in the INI syntax
+func isINIConfig(data []byte) bool {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			return true
+		}
+		key, _, ok := strings.Cut(line, "=")
+		return ok && !strings.Contains(key, ":")
+	}
+	return false
+}
+
+func (cfg *cliConfig) unmarshalINI(data []byte) error {
+	file, err := ini.Load(data)
+	if err != nil {
+		return err
+	}
+	cfg.CurrentContext = file.Section(ini.DefaultSection).Key(iniCurrentContextKey).String()
+
+	for _, section := range file.Sections() {
+		if section.Name() == ini.DefaultSection {
+			continue
+		}
+		ctx := &configContext{}
+		for _, key := range section.Keys() {
+			target := ctx.field(key.Name())
+			if target == nil {
+				return fmt.Errorf("unknown field %q in context %q", key.Name(), section.Name())
+			}
+			*target = key.String()
+		}
+		cfg.Contexts[section.Name()] = ctx
+	}
+	return nil
+}
+
+func (cfg *cliConfig) marshalINI() ([]byte, error) {
+	file := ini.Empty()
+	if cfg.CurrentContext != "" {
+		file.Section(ini.DefaultSection).Key(iniCurrentContextKey).SetValue(cfg.CurrentContext)
+	}
+	for _, name := range cfg.contextNames() {
+		section, err := file.NewSection(name)
+		if err != nil {
+			return nil, err
+		}
+		ctx := cfg.Contexts[name]
+		for _, field := range contextFields {
+			if value := *ctx.field(field); value != "" {
+				section.Key(field).SetValue(value)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := file.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// save writes the configuration back in the format it was read in
+func (cfg *cliConfig) save() error {
+	var data []byte
+	var err error
+	if cfg.format == configFormatINI {
+		data, err = cfg.marshalINI()
+	} else {
+		data, err = yaml.Marshal(cfg)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode config file: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cfg.path), 0700); err != nil {
+		return fmt.Errorf("failed to create config directory: %w", err)
+	}
+	// The file may hold API keys
+	if err := os.WriteFile(cfg.path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+func (cfg *cliConfig) contextNames() []string {
+	names := make([]string, 0, len(cfg.Contexts))
+	for name := range cfg.Contexts {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// selectedContextName returns the context named by --context, then HLB_CONTEXT, then the current
+// context of the file
+func (cfg *cliConfig) selectedContextName() string {
+	if contextName != "" {
+		return contextName
+	}
+	if name := os.Getenv("HLB_CONTEXT"); name != "" {
+		return name
+	}
+	return cfg.CurrentContext
+}
+
+// field returns the setting stored under a name of contextFields, or nil for unknown names
+func (c *configContext) field(name string) *string {
+	switch name {
+	case "api-key":
+		return &c.APIKey
+	case "api-key-env":
+		return &c.APIKeyEnv
+	case "api-key-command":
+		return &c.APIKeyCommand
+	case "profile":
+		return &c.Profile
+	case "region":
+		return &c.Region
+	case "partition":
+		return &c.Partition
+	case "endpoint":
+		return &c.Endpoint
+	case "output":
+		return &c.Output
+	}
+	return nil
+}
+
+func (c *configContext) validate() error {
+	if c.Endpoint != "" {
+		u, err := url.Parse(c.Endpoint)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("endpoint %q must be an absolute URL such as https://hlb.example.com/v1", c.Endpoint)
+		}
+	}
+	return nil
+}
+
+// apiKeySource describes where the API key of the context comes from, without revealing it
+func (c *configContext) apiKeySource() string {
+	switch {
+	case c.APIKey != "":
+		return hlb.RedactSecret(c.APIKey)
+	case c.APIKeyEnv != "":
+		return "$" + c.APIKeyEnv
+	case c.APIKeyCommand != "":
+		return "command: " + c.APIKeyCommand
+	}
+	return ""
+}
+
+// resolveAPIKey returns the API key of the context, or an empty string if it has no API key source
+func (c *configContext) resolveAPIKey() (string, error) {
+	switch {
+	case c.APIKey != "":
+		return c.APIKey, nil
+	case c.APIKeyEnv != "":
+		return os.Getenv(c.APIKeyEnv), nil
+	case c.APIKeyCommand != "":
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.Command("cmd", "/C", c.APIKeyCommand)
+		} else {
+			cmd = exec.Command("sh", "-c", c.APIKeyCommand)
+		}
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("api-key-command %q failed: %w", c.APIKeyCommand, err)
+		}
+		return strings.TrimSpace(string(out)), nil
+	}
+	return "", nil
+}
+
+// applyContext selects the context of the command and applies its settings to the global flags
+// that were not set on the command line
+func applyContext(cmd *cobra.Command) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	name := cfg.selectedContextName()
+	if name == "" {
+		return nil
+	}
+	ctx, ok := cfg.Contexts[name]
+	if !ok {
+		// The current context of the file is only a default, a context asked for must exist
+		if name == cfg.CurrentContext {
+			return fmt.Errorf("current context %q not found in %s, select another one with zonehero config use-context", name, cfg.path)
+		}
+		return fmt.Errorf("context %q not found in %s", name, cfg.path)
+	}
+	activeContext = ctx
+
+	flags := cmd.Root().PersistentFlags()
+	for _, setting := range []struct {
+		flag   string
+		target *string
+		value  string
+	}{
+		{"profile", &profile, ctx.Profile},
+		{"region", &region, ctx.Region},
+		{"partition", &partition, ctx.Partition},
+		{"output", &output, ctx.Output},
+	} {
+		if setting.value != "" && !flags.Changed(setting.flag) {
+			*setting.target = setting.value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/spf13/cobra"
+	"gitlab.guerraz.net/HLB/hlb-terraform-provider/hlb"
+)
+
+func init() {
+	// Credentials Commands
+	rootCmd.AddCommand(credentialsCmd)
+	rootCmd.AddCommand(whoamiCmd)
+	credentialsCmd.AddCommand(showCredentialsCmd)
+	credentialsCmd.AddCommand(refreshCredentialsCmd)
+	credentialsCmd.AddCommand(clearCredentialsCmd)
+
+	// Clear Credentials Flags
+	clearCredentialsCmd.Flags().Bool("all", false, "Clear cached credentials for every API key and region")
+	clearCredentialsCmd.Flags().String("region", "", "Only clear cached credentials for this region")
+	clearCredentialsCmd.MarkFlagsMutuallyExclusive("all", "region")
+}
+
+var credentialsCmd = &cobra.Command{
+	Use:   "credentials",
+	Short: "Inspect and manage cached HLB API credentials",
+	Long: `Inspect and manage the HLB API credentials cached in ~/.hlb/credentials.
+Cached entries hold the presigned STS headers sent with every API request, keyed by API key, region and
+API hostname.`,
+}
+
+var showCredentialsCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show cached credentials",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entries, err := hlb.ListCachedCredentials()
+		if err != nil {
+			return err
+		}
+
+		for i := range entries {
+			entries[i].APIKey = hlb.RedactSecret(entries[i].APIKey)
+		}
+
+		t := &table{headers: []string{"API KEY", "ACCOUNT", "REGION", "HOSTNAME", "PARTITION", "EXPIRY"}}
+		for _, e := range entries {
+			t.rows = append(t.rows, []string{e.APIKey, e.AccountID, e.Region, orNone(e.Hostname), orNone(e.Partition), formatExpiry(e.Expiry)})
+		}
+		return outputPrinter.printList(map[string]interface{}{"items": entries}, t)
+	},
+}
+
+var refreshCredentialsCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Regenerate cached credentials for the current API key and region",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := createClient(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		if err := client.RefreshCredentials(cmd.Context()); err != nil {
+			return err
+		}
+
+		expiry := client.GetCredentialsExpiry()
+		return outputPrinter.printMessage(map[string]interface{}{
+			"accountId": client.GetAccountID(),
+			"region":    client.GetRegion(),
+			"partition": client.GetPartition(),
+			"expiry":    expiry,
+		}, "Refreshed credentials for account %s in %s (expires %s)", client.GetAccountID(), client.GetRegion(), expiry.Local().Format(time.RFC3339))
+	},
+}
+
+var clearCredentialsCmd = &cobra.Command{
+	Use:   "clear",
+	Short: "Clear cached credentials",
+	Long: `Clear cached credentials for the current API key.
+Use --region to only clear a single region, or --all to clear the cache for every API key.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		all, _ := cmd.Flags().GetBool("all")
+		clearRegion, _ := cmd.Flags().GetString("region")
+
+		key := ""
+		if !all {
+			var err error
+			if key, err = resolveAPIKey(); err != nil {
+				return err
+			}
+		}
+
+		if err := hlb.ClearCachedCredentials(key, clearRegion); err != nil {
+			return err
+		}
+
+		status := map[string]string{"status": "cleared"}
+		switch {
+		case all:
+			return outputPrinter.printMessage(status, "Cleared all cached credentials")
+		case clearRegion != "":
+			return outputPrinter.printMessage(status, "Cleared cached credentials for %s in %s", hlb.RedactSecret(key), clearRegion)
+		default:
+			return outputPrinter.printMessage(status, "Cleared cached credentials for %s", hlb.RedactSecret(key))
+		}
+	},
+}
+
+var whoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Show the resolved AWS identity, HLB role and API endpoint",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		awsCfg, err := loadAWSConfig(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		identity, err := sts.NewFromConfig(awsCfg).GetCallerIdentity(cmd.Context(), &sts.GetCallerIdentityInput{})
+		if err != nil {
+			return fmt.Errorf("error getting AWS caller identity: %v", err)
+		}
+
+		client, err := createClient(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		return outputPrinter.printObject(map[string]interface{}{
+			"arn":       aws.ToString(identity.Arn),
+			"userId":    aws.ToString(identity.UserId),
+			"accountId": aws.ToString(identity.Account),
+			"hlbRole":   client.GetRoleARN(),
+			"hostname":  client.GetHostname(),
+			"region":    client.GetRegion(),
+			"partition": client.GetPartition(),
+		}, func(w io.Writer) {
+			d := newDescribeWriter(w)
+			defer d.flush()
+
+			d.field("AWS Identity", aws.ToString(identity.Arn))
+			d.field("AWS Account", aws.ToString(identity.Account))
+			d.field("HLB Role", client.GetRoleARN())
+			d.field("API Hostname", client.GetHostname())
+			d.field("Region", client.GetRegion())
+			d.field("Partition", client.GetPartition())
+		})
+	},
+}
+
+// formatExpiry formats the expiry of cached credentials in local time, noting when it has passed
+func formatExpiry(expiry time.Time) string {
+	if expiry.IsZero() {
+		return "unknown"
+	}
+	if time.Now().After(expiry) {
+		return expiry.Local().Format(time.RFC3339) + " (expired)"
+	}
+	return expiry.Local().Format(time.RFC3339)
+}
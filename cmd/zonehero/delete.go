@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"gitlab.guerraz.net/HLB/hlb-terraform-provider/hlb"
+)
+
+// deleteOptions controls how protected resources and listeners are handled by deletions
+type deleteOptions struct {
+	// disableProtection turns deletion protection off before deleting
+	disableProtection bool
+	// cascade deletes the listeners of a load balancer before the load balancer itself
+	cascade bool
+	events  io.Writer
+}
+
+// addDeleteFlags registers the flags read by deleteOptionsFromFlags
+func addDeleteFlags(cmd *cobra.Command, cascade bool) {
+	cmd.Flags().Bool("disable-protection", false, "Turn deletion protection off before deleting")
+	if cascade {
+		cmd.Flags().Bool("cascade", false, "Delete the listeners of the load balancer before the load balancer")
+	}
+	if cmd.Flags().Lookup("yes") == nil {
+		cmd.Flags().BoolP("yes", "y", false, "Do not ask for confirmation")
+	}
+}
+
+func deleteOptionsFromFlags(cmd *cobra.Command) deleteOptions {
+	disableProtection, _ := cmd.Flags().GetBool("disable-protection")
+	cascade, _ := cmd.Flags().GetBool("cascade")
+	return deleteOptions{disableProtection: disableProtection, cascade: cascade, events: outputPrinter.events()}
+}
+
+// checkLoadBalancerDeletable fails early with an actionable message when deletion protection
+// would make the API reject the deletion of lb or of one of the listeners deleted with it
+func (o deleteOptions) checkLoadBalancerDeletable(lb *hlb.LoadBalancer, listeners []hlb.Listener) error {
+	if o.disableProtection {
+		return nil
+	}
+	if lb.EnableDeletionProtection {
+		return fmt.Errorf("load balancer %s (%s) has deletion protection enabled, use --disable-protection to turn it off and delete it", lb.Name, lb.ID)
+	}
+	if o.cascade {
+		for _, l := range listeners {
+			if l.EnableDeletionProtection {
+				return fmt.Errorf("listener %s (%d/%s) of load balancer %s has deletion protection enabled, use --disable-protection to turn it off and delete it", l.ID, l.Port, l.Protocol, lb.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// deleteLoadBalancer deletes lb, turning deletion protection off and deleting listeners first as
+// requested by the options
+func (o deleteOptions) deleteLoadBalancer(ctx context.Context, client *hlb.Client, lb *hlb.LoadBalancer, listeners []hlb.Listener) error {
+	if err := o.checkLoadBalancerDeletable(lb, listeners); err != nil {
+		return err
+	}
+
+	if o.cascade {
+		for i := range listeners {
+			listeners[i].LoadBalancerID = lb.ID
+			if err := o.deleteListener(ctx, client, &listeners[i]); err != nil {
+				return err
+			}
+		}
+	}
+
+	if lb.EnableDeletionProtection {
+		fmt.Fprintf(o.events, "Disabling deletion protection of load balancer %s (%s)...\n", lb.Name, lb.ID)
+		disabled := false
+		if _, err := client.UpdateLoadBalancer(ctx, lb.ID, &hlb.LoadBalancerUpdate{EnableDeletionProtection: &disabled}); err != nil {
+			return fmt.Errorf("failed to disable deletion protection of load balancer %s: %w", lb.ID, err)
+		}
+	}
+
+	return client.DeleteLoadBalancer(ctx, lb.ID)
+}
+
+// checkListenerDeletable fails early when deletion protection would make the API reject the
+// deletion of l
+func (o deleteOptions) checkListenerDeletable(l *hlb.Listener) error {
+	if l.EnableDeletionProtection && !o.disableProtection {
+		return fmt.Errorf("listener %s (%d/%s) has deletion protection enabled, use --disable-protection to turn it off and delete it", l.ID, l.Port, l.Protocol)
+	}
+	return nil
+}
+
+// deleteListener deletes l, turning deletion protection off first when requested
+func (o deleteOptions) deleteListener(ctx context.Context, client *hlb.Client, l *hlb.Listener) error {
+	if err := o.checkListenerDeletable(l); err != nil {
+		return err
+	}
+	if l.EnableDeletionProtection {
+		fmt.Fprintf(o.events, "Disabling deletion protection of listener %s...\n", l.ID)
+		disabled := false
+		// The overprovisioning factor is always sent by updates, keep the current one
+		update := &hlb.ListenerUpdate{EnableDeletionProtection: &disabled, OverprovisioningFactor: &l.OverprovisioningFactor}
+		if _, err := client.UpdateListener(ctx, l.LoadBalancerID, l.ID, update); err != nil {
+			return fmt.Errorf("failed to disable deletion protection of listener %s: %w", l.ID, err)
+		}
+	}
+
+	if err := client.DeleteListener(ctx, l.LoadBalancerID, l.ID); err != nil {
+		return err
+	}
+	fmt.Fprintf(o.events, "Deleted listener: %s (%d/%s)\n", l.ID, l.Port, l.Protocol)
+	return nil
+}
+
+// writeLoadBalancerDeletionSummary shows what a load balancer deletion affects before confirming it
+func writeLoadBalancerDeletionSummary(w io.Writer, lb *hlb.LoadBalancer, listeners []hlb.Listener, o deleteOptions) {
+	d := newDescribeWriter(w)
+	defer d.flush()
+
+	d.field("Name", lb.Name)
+	d.field("ID", lb.ID)
+	d.field("DNS Name", orNone(lb.DNSName))
+	d.field("State", lb.State)
+	d.field("Listeners", len(listeners))
+	d.field("Deletion Protection", lb.EnableDeletionProtection)
+	if o.cascade && len(listeners) > 0 {
+		d.field("Cascade", fmt.Sprintf("the %d listeners will be deleted first", len(listeners)))
+	}
+}
+
+// writeLoadBalancersDeletionSummary shows what a bulk load balancer deletion affects before
+// confirming it
+func writeLoadBalancersDeletionSummary(w io.Writer, loadBalancers []hlb.LoadBalancer, listeners map[string][]hlb.Listener) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tDNS NAME\tLISTENERS\tPROTECTED")
+	for _, lb := range loadBalancers {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%t\n", lb.ID, lb.Name, orNone(lb.DNSName), len(listeners[lb.ID]), lb.EnableDeletionProtection)
+	}
+	tw.Flush()
+}
+
+// runDeleteLoadBalancers confirms and deletes the load balancers selected by --id or the selector flags
+func runDeleteLoadBalancers(cmd *cobra.Command, client *hlb.Client) error {
+	opts := deleteOptionsFromFlags(cmd)
+
+	targets, bulk, err := selectLoadBalancers(cmd, client)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return outputPrinter.printMessage(map[string]interface{}{"deleted": []string{}}, "No load balancers match the selector")
+	}
+
+	listeners := make(map[string][]hlb.Listener, len(targets))
+	for i := range targets {
+		lb := &targets[i]
+		if listeners[lb.ID], err = listAllListeners(cmd.Context(), client, lb.ID); err != nil {
+			return err
+		}
+		if err := opts.checkLoadBalancerDeletable(lb, listeners[lb.ID]); err != nil {
+			return err
+		}
+	}
+
+	question := fmt.Sprintf("Delete %d load balancers?", len(targets))
+	if bulk {
+		writeLoadBalancersDeletionSummary(os.Stderr, targets, listeners)
+	} else {
+		writeLoadBalancerDeletionSummary(os.Stderr, &targets[0], listeners[targets[0].ID], opts)
+		question = fmt.Sprintf("Delete load balancer %s?", targets[0].Name)
+	}
+	if err := confirm(cmd, question); err != nil {
+		return err
+	}
+
+	deleted := []string{}
+	for i := range targets {
+		lb := &targets[i]
+		if err := opts.deleteLoadBalancer(cmd.Context(), client, lb, listeners[lb.ID]); err != nil {
+			return fmt.Errorf("failed to delete load balancer %s (%s): %w", lb.Name, lb.ID, err)
+		}
+		deleted = append(deleted, lb.ID)
+		if bulk {
+			fmt.Fprintf(opts.events, "Deleted load balancer: %s\n", lb.ID)
+		}
+	}
+
+	if !bulk {
+		return outputPrinter.printMessage(map[string]string{"status": "deleted"}, "Deleted load balancer: %s", deleted[0])
+	}
+	return outputPrinter.printMessage(map[string]interface{}{"deleted": deleted}, "Deleted %d load balancers", len(deleted))
+}
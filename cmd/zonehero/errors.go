@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gitlab.guerraz.net/HLB/hlb-terraform-provider/hlb"
+	"gopkg.in/yaml.v3"
+)
+
+// Exit codes of zonehero, one per class of error. They are part of the CLI interface: wrappers
+// rely on them, so existing codes must never change meaning.
+const (
+	exitError              = 1  // any error not covered by a more specific code
+	exitChangesPending     = 2  // diff --exit-code found changes
+	exitUsage              = 3  // invalid command line or request rejected as invalid by the API
+	exitAuth               = 4  // missing API key, credentials that cannot be generated or access denied
+	exitNotFound           = 5  // the resource does not exist
+	exitConflict           = 6  // the resource is in a state that does not allow the operation
+	exitThrottled          = 7  // still rate limited after every retry
+	exitServerError        = 8  // the API failed to process the request
+	exitLoadBalancerFailed = 9  // a load balancer entered the failed state
+	exitTimeout            = 10 // the operation did not complete in time
+	exitAborted            = 11 // the confirmation of the operation was declined
+)
+
+// Error codes reported in structured error output
+const (
+	errorCodeError              = "Error"
+	errorCodeChangesPending     = "ChangesPending"
+	errorCodeUsage              = "InvalidRequest"
+	errorCodeAuth               = "Unauthorized"
+	errorCodeNotFound           = "NotFound"
+	errorCodeConflict           = "Conflict"
+	errorCodeThrottled          = "Throttled"
+	errorCodeServerError        = "ServerError"
+	errorCodeLoadBalancerFailed = "LoadBalancerFailed"
+	errorCodeTimeout            = "Timeout"
+	errorCodeAborted            = "Aborted"
+)
+
+// exitCodesHelp documents the exit codes in the help of the root command
+const exitCodesHelp = `Exit codes:
+  0   Success
+  1   Error not covered by another code
+  2   Changes pending (diff --exit-code)
+  3   Invalid command line, or request rejected as invalid by the API
+  4   Authentication or authorization failure
+  5   Resource not found
+  6   Conflict with the current state of the resource
+  7   Throttled by the API after every retry
+  8   API server error
+  9   Load balancer entered the failed state
+  10  Timed out
+  11  Aborted, the confirmation was declined`
+
+// errMissingAPIKey is returned when no API key is configured
+var errMissingAPIKey = errors.New("HLB API key is required. Set it using --api-key flag, HLB_API_KEY environment variable or a context of ~/.hlb/config")
+
+// errAborted is returned when the confirmation of an operation is declined
+var errAborted = errors.New("aborted, the confirmation was declined")
+
+// resumableCreateError tells how to resume a creation of kind whose outcome is unknown, by sending
+// it again with the same client token
+func resumableCreateError(err error, kind, clientToken string) error {
+	if !hlb.IsOutcomeUnknown(err) {
+		return err
+	}
+	return fmt.Errorf("%w. The %s may have been created, run the command again with --client-token %s to get it instead of creating another one", err, kind, clientToken)
+}
+
+// usageError marks errors in the command line itself, such as unknown flags or missing arguments
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string {
+	return e.err.Error()
+}
+
+func (e *usageError) Unwrap() error {
+	return e.err
+}
+
+// cliError is the structured form of an error, written to stderr with the json and yaml formats
+type cliError struct {
+	Code       string `json:"code" yaml:"code"`
+	Message    string `json:"message" yaml:"message"`
+	HTTPStatus int    `json:"httpStatus,omitempty" yaml:"httpStatus,omitempty"`
+	RequestID  string `json:"requestId,omitempty" yaml:"requestId,omitempty"`
+	ExitCode   int    `json:"exitCode" yaml:"exitCode"`
+}
+
+// classifyError maps err to its error code and exit code
+func classifyError(err error) cliError {
+	e := cliError{Code: errorCodeError, Message: err.Error(), ExitCode: exitError}
+
+	var apiErr *hlb.APIErrorResponse
+	var failedErr *hlb.LoadBalancerFailedError
+	var credentialsErr *hlb.CredentialsError
+	var usageErr *usageError
+	switch {
+	case errors.Is(err, errChangesPending):
+		e.Code, e.ExitCode = errorCodeChangesPending, exitChangesPending
+	case errors.Is(err, errAborted):
+		e.Code, e.ExitCode = errorCodeAborted, exitAborted
+	case errors.As(err, &usageErr):
+		e.Code, e.ExitCode = errorCodeUsage, exitUsage
+	case errors.Is(err, errMissingAPIKey), errors.As(err, &credentialsErr):
+		e.Code, e.ExitCode = errorCodeAuth, exitAuth
+	case errors.As(err, &failedErr):
+		e.Code, e.ExitCode = errorCodeLoadBalancerFailed, exitLoadBalancerFailed
+	case errors.As(err, &apiErr):
+		e.HTTPStatus, e.RequestID = apiErr.StatusCode, apiErr.RequestID
+		e.Code, e.ExitCode = classifyHTTPStatus(apiErr.StatusCode)
+	case errors.Is(err, context.DeadlineExceeded):
+		e.Code, e.ExitCode = errorCodeTimeout, exitTimeout
+	}
+	return e
+}
+
+func classifyHTTPStatus(status int) (string, int) {
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return errorCodeAuth, exitAuth
+	case status == http.StatusNotFound:
+		return errorCodeNotFound, exitNotFound
+	case status == http.StatusConflict || status == http.StatusPreconditionFailed:
+		return errorCodeConflict, exitConflict
+	case status == http.StatusTooManyRequests:
+		return errorCodeThrottled, exitThrottled
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout:
+		return errorCodeTimeout, exitTimeout
+	case status >= 500:
+		return errorCodeServerError, exitServerError
+	case status >= 400:
+		return errorCodeUsage, exitUsage
+	}
+	return errorCodeError, exitError
+}
+
+// reportError writes err to w in the format selected with --output and returns the exit code
+func reportError(w io.Writer, err error) int {
+	e := classifyError(err)
+
+	// The plan printed by diff already tells what changed
+	if e.ExitCode == exitChangesPending {
+		return e.ExitCode
+	}
+
+	// The printer is not created when --output itself is invalid
+	format, _, _ := strings.Cut(output, "=")
+	switch format {
+	case outputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(e)
+	case outputYAML:
+		yaml.NewEncoder(w).Encode(e)
+	default:
+		if e.ExitCode == exitAborted {
+			fmt.Fprintln(w, "Aborted")
+			return e.ExitCode
+		}
+		fmt.Fprintf(w, "Error: %s\n", e.Message)
+		if e.RequestID != "" {
+			fmt.Fprintf(w, "Request ID: %s\n", e.RequestID)
+		}
+		if e.ExitCode == exitUsage && !errors.As(err, new(*hlb.APIErrorResponse)) {
+			fmt.Fprintln(w, "Run 'zonehero --help' for usage.")
+		}
+	}
+	return e.ExitCode
+}
+
+// trackCommandStart records in commandStarted when the RunE of cmd or of one of its subcommands
+// is called, to tell errors of the command line, such as unknown or missing required flags, from
+// errors of the command
+func trackCommandStart(cmd *cobra.Command) {
+	if cmd.RunE != nil {
+		runE := cmd.RunE
+		cmd.RunE = func(cmd *cobra.Command, args []string) error {
+			commandStarted = true
+			return runE(cmd, args)
+		}
+	}
+	for _, sub := range cmd.Commands() {
+		trackCommandStart(sub)
+	}
+}
+
+// commandStarted is set once the RunE of the executed command is called. Errors returned before
+// are errors in the command line.
+var commandStarted bool
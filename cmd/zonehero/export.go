@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	"github.com/spf13/cobra"
+	"github.com/zclconf/go-cty/cty"
+	"gitlab.guerraz.net/HLB/hlb-terraform-provider/hlb"
+)
+
+func init() {
+	// Export Commands
+	hlbCmd.AddCommand(exportCmd)
+	exportCmd.AddCommand(exportTerraformCmd)
+
+	// Export Terraform Flags
+	exportTerraformCmd.Flags().StringSlice("id", []string{}, "ID of the load balancer to export (can be repeated)")
+	exportTerraformCmd.Flags().Bool("all", false, "Export every load balancer")
+	exportTerraformCmd.Flags().String("out", "", "Directory in which to write one .tf file per load balancer (default: print to stdout)")
+	exportTerraformCmd.MarkFlagsMutuallyExclusive("id", "all")
+	exportTerraformCmd.MarkFlagsOneRequired("id", "all")
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export existing resources to other tools",
+}
+
+var exportTerraformCmd = &cobra.Command{
+	Use:   "terraform",
+	Short: "Export load balancers and listeners as Terraform configuration",
+	Long: `Export load balancers and their listeners as hlb_load_balancer and hlb_listener_attachment
+resources, together with Terraform 1.5 import blocks. Attributes left at their provider default
+are omitted, so running terraform plan on the generated configuration imports the resources
+without proposing any change.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ids, _ := cmd.Flags().GetStringSlice("id")
+		all, _ := cmd.Flags().GetBool("all")
+		outDir, _ := cmd.Flags().GetString("out")
+
+		client, err := createClient(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		var loadBalancers []hlb.LoadBalancer
+		if all {
+			if loadBalancers, err = listAllLoadBalancers(cmd.Context(), client); err != nil {
+				return err
+			}
+		} else {
+			for _, id := range ids {
+				lb, err := client.GetLoadBalancer(cmd.Context(), id)
+				if err != nil {
+					return err
+				}
+				loadBalancers = append(loadBalancers, *lb)
+			}
+		}
+
+		names := map[string]bool{}
+		var files []string
+		var combined bytes.Buffer
+		for i := range loadBalancers {
+			lb := &loadBalancers[i]
+			if all && !isLiveLoadBalancerState(lb.State) {
+				continue
+			}
+
+			listeners, err := listAllListeners(cmd.Context(), client, lb.ID)
+			if err != nil {
+				return err
+			}
+
+			name := uniqueResourceName(terraformResourceName(lb.Name), names)
+			body := exportLoadBalancer(lb, name, listeners, names)
+
+			if outDir == "" {
+				if combined.Len() > 0 {
+					combined.WriteString("\n")
+				}
+				combined.Write(hclwrite.Format(body.Bytes()))
+				continue
+			}
+
+			if err := os.MkdirAll(outDir, 0755); err != nil {
+				return fmt.Errorf("failed to create output directory: %w", err)
+			}
+			file := filepath.Join(outDir, name+".tf")
+			if _, err := os.Stat(file); err == nil {
+				return fmt.Errorf("refusing to overwrite existing file %s", file)
+			}
+			if err := os.WriteFile(file, hclwrite.Format(body.Bytes()), 0644); err != nil {
+				return fmt.Errorf("failed to write %s: %w", file, err)
+			}
+			files = append(files, file)
+		}
+
+		if outDir == "" {
+			_, err := io.Copy(os.Stdout, &combined)
+			return err
+		}
+		return outputPrinter.printMessage(map[string]interface{}{"files": files}, "Wrote %d files to %s", len(files), outDir)
+	},
+}
+
+// exportLoadBalancer renders a load balancer, its listeners and the matching import blocks.
+// Optional attributes are only written when they differ from the provider schema defaults.
+func exportLoadBalancer(lb *hlb.LoadBalancer, name string, listeners []hlb.Listener, names map[string]bool) *hclwrite.File {
+	f := hclwrite.NewEmptyFile()
+	root := f.Body()
+	address := hcl.Traversal{hcl.TraverseRoot{Name: "hlb_load_balancer"}, hcl.TraverseAttr{Name: name}}
+
+	importBlock := root.AppendNewBlock("import", nil).Body()
+	importBlock.SetAttributeTraversal("to", address)
+	importBlock.SetAttributeValue("id", cty.StringVal(lb.ID))
+	root.AppendNewline()
+
+	b := root.AppendNewBlock("resource", []string{"hlb_load_balancer", name}).Body()
+	b.SetAttributeValue("name", cty.StringVal(lb.Name))
+	b.SetAttributeValue("zone_id", cty.StringVal(lb.ZoneID))
+	b.SetAttributeValue("zone_name", cty.StringVal(lb.ZoneName))
+	b.SetAttributeValue("subnets", stringListValue(lb.Subnets))
+	if len(lb.SecurityGroups) > 0 {
+		b.SetAttributeValue("security_groups", stringListValue(lb.SecurityGroups))
+	}
+	if lb.Internal {
+		b.SetAttributeValue("internal", cty.True)
+	}
+	if lb.IPAddressType != "" && lb.IPAddressType != hlb.LBIpAddressTypeV4Only {
+		b.SetAttributeValue("ip_address_type", cty.StringVal(lb.IPAddressType))
+	}
+	if lb.Ec2IamRole != "" && lb.Ec2IamRole != hlb.LBEc2IamRoleStandard {
+		b.SetAttributeValue("ec2_iam_role", cty.StringVal(lb.Ec2IamRole))
+	}
+	if lb.EnableDeletionProtection {
+		b.SetAttributeValue("enable_deletion_protection", cty.True)
+	}
+	if !lb.EnableHttp2 {
+		b.SetAttributeValue("enable_http2", cty.False)
+	}
+	if lb.IdleTimeout != 60 {
+		b.SetAttributeValue("idle_timeout", cty.NumberIntVal(int64(lb.IdleTimeout)))
+	}
+	if lb.PreserveHostHeader {
+		b.SetAttributeValue("preserve_host_header", cty.True)
+	}
+	if lb.EnableCrossZoneLoadBalancing != "" && lb.EnableCrossZoneLoadBalancing != hlb.LBCrossAZPolicyAvoid {
+		b.SetAttributeValue("enable_cross_zone_load_balancing", cty.StringVal(lb.EnableCrossZoneLoadBalancing))
+	}
+	if lb.ClientKeepAlive != 3600 {
+		b.SetAttributeValue("client_keep_alive", cty.NumberIntVal(int64(lb.ClientKeepAlive)))
+	}
+	if lb.XffHeaderProcessingMode != "" && lb.XffHeaderProcessingMode != "append" {
+		b.SetAttributeValue("xff_header_processing_mode", cty.StringVal(lb.XffHeaderProcessingMode))
+	}
+	if lb.ConnectionDrainingTimeout != 10 {
+		b.SetAttributeValue("connection_draining_timeout", cty.NumberIntVal(int64(lb.ConnectionDrainingTimeout)))
+	}
+	if lb.PreferredMaintenanceWindow != "" {
+		b.SetAttributeValue("preferred_maintenance_window", cty.StringVal(lb.PreferredMaintenanceWindow))
+	}
+
+	// The provider reads every nested attribute back from the API, so write them all
+	if lb.AccessLogs != nil {
+		accessLogs := map[string]cty.Value{
+			"bucket": cty.StringVal(lb.AccessLogs.Bucket),
+			"prefix": cty.StringVal(lb.AccessLogs.Prefix),
+		}
+		if lb.AccessLogs.Enabled {
+			accessLogs["enabled"] = cty.True
+		}
+		b.AppendNewline()
+		b.SetAttributeValue("access_logs", cty.ObjectVal(accessLogs))
+	}
+	if lb.LaunchConfig != nil {
+		b.AppendNewline()
+		b.SetAttributeValue("launch_config", cty.ObjectVal(map[string]cty.Value{
+			"instance_type":      cty.StringVal(lb.LaunchConfig.InstanceType),
+			"min_instance_count": cty.NumberIntVal(int64(lb.LaunchConfig.MinInstanceCount)),
+			"max_instance_count": cty.NumberIntVal(int64(lb.LaunchConfig.MaxInstanceCount)),
+			"target_cpu_usage":   cty.NumberIntVal(int64(lb.LaunchConfig.TargetCPUUsage)),
+		}))
+	}
+	if len(lb.Tags) > 0 {
+		tags := make(map[string]cty.Value, len(lb.Tags))
+		for k, v := range lb.Tags {
+			tags[k] = cty.StringVal(v)
+		}
+		b.AppendNewline()
+		b.SetAttributeValue("tags", cty.MapVal(tags))
+	}
+
+	sort.Slice(listeners, func(i, j int) bool {
+		return listeners[i].Port < listeners[j].Port
+	})
+	for _, l := range listeners {
+		listenerName := uniqueResourceName(fmt.Sprintf("%s_%s_%d", name, strings.ToLower(l.Protocol), l.Port), names)
+
+		root.AppendNewline()
+		importBlock := root.AppendNewBlock("import", nil).Body()
+		importBlock.SetAttributeTraversal("to", hcl.Traversal{hcl.TraverseRoot{Name: "hlb_listener_attachment"}, hcl.TraverseAttr{Name: listenerName}})
+		importBlock.SetAttributeValue("id", cty.StringVal(lb.ID+"/"+l.ID))
+
+		root.AppendNewline()
+		attachment := root.AppendNewBlock("resource", []string{"hlb_listener_attachment", listenerName}).Body()
+		attachment.SetAttributeTraversal("load_balancer_id", append(address, hcl.TraverseAttr{Name: "id"}))
+		attachment.SetAttributeValue("port", cty.NumberIntVal(int64(l.Port)))
+		attachment.SetAttributeValue("protocol", cty.StringVal(l.Protocol))
+		attachment.SetAttributeValue("target_group_arn", cty.StringVal(l.TargetGroupARN))
+		if l.CertificateSecretsName != "" {
+			attachment.SetAttributeValue("certificate_secrets_name", cty.StringVal(l.CertificateSecretsName))
+		}
+		if l.ALPNPolicy != "" {
+			attachment.SetAttributeValue("alpn_policy", cty.StringVal(l.ALPNPolicy))
+		}
+		if l.EnableDeletionProtection {
+			attachment.SetAttributeValue("enable_deletion_protection", cty.True)
+		}
+		if l.OverprovisioningFactor != 1.1 {
+			attachment.SetAttributeValue("overprovisioning_factor", cty.NumberFloatVal(l.OverprovisioningFactor))
+		}
+	}
+
+	return f
+}
+
+var nonIdentifierChars = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// terraformResourceName turns a load balancer name into a valid Terraform resource name
+func terraformResourceName(name string) string {
+	name = strings.Trim(nonIdentifierChars.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "lb_" + name
+	}
+	return name
+}
+
+// uniqueResourceName suffixes name until it is not in used, then records it
+func uniqueResourceName(name string, used map[string]bool) string {
+	candidate := name
+	for i := 2; used[candidate]; i++ {
+		candidate = fmt.Sprintf("%s_%d", name, i)
+	}
+	used[candidate] = true
+	return candidate
+}
+
+// stringListValue returns values as a sorted list, matching set semantics
+func stringListValue(values []string) cty.Value {
+	if len(values) == 0 {
+		return cty.ListValEmpty(cty.String)
+	}
+	sorted := slices.Sorted(slices.Values(values))
+	list := make([]cty.Value, len(sorted))
+	for i, v := range sorted {
+		list[i] = cty.StringVal(v)
+	}
+	return cty.ListVal(list)
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// errSkeletonPrinted stops a command once --generate-skeleton printed its input template
+var errSkeletonPrinted = errors.New("input skeleton printed")
+
+// inputFieldDescriptions documents the input fields that have no flag of the same name
+var inputFieldDescriptions = map[string]string{
+	"tags":                          "Tags to assign to the load balancer",
+	"launchConfig":                  "Launch configuration of the load balancer instances",
+	"launchConfig.instanceType":     "EC2 instance type of the load balancer instances",
+	"launchConfig.minInstanceCount": "Minimum number of load balancer instances",
+	"launchConfig.maxInstanceCount": "Maximum number of load balancer instances",
+	"launchConfig.targetCpuUsage":   "Average CPU usage in percent that the instance count is scaled to",
+	"accessLogs":                    "Access logs configuration",
+}
+
+// addInputFlags registers --input-json and --generate-skeleton. template is the request type read
+// from the input file, whose commented skeleton is printed by --generate-skeleton.
+func addInputFlags(cmd *cobra.Command, usage string, template interface{}) {
+	cmd.Flags().String("input-json", "", usage+" in JSON or YAML, - to read from stdin. Flags override its values")
+	cmd.Flags().Bool("generate-skeleton", false, "Print a commented template of the input accepted by --input-json and exit")
+
+	// Print the skeleton before the required flags are checked
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if skeleton, _ := cmd.Flags().GetBool("generate-skeleton"); !skeleton {
+			return nil
+		}
+		if err := writeSkeleton(os.Stdout, cmd, template); err != nil {
+			return err
+		}
+		return errSkeletonPrinted
+	}
+}
+
+// readInput decodes the file named by --input-json into v. It returns false if no input file is set.
+func readInput(cmd *cobra.Command, v interface{}) (bool, error) {
+	path, _ := cmd.Flags().GetString("input-json")
+	if path == "" {
+		return false, nil
+	}
+
+	var data []byte
+	var err error
+	source := path
+	if path == "-" {
+		source = "stdin"
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read input %s: %w", source, err)
+	}
+
+	if err := decodeInput(source, data, v); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// requireFlagsWithoutInput fails when one of the flags is missing and no input file is set
+func requireFlagsWithoutInput(cmd *cobra.Command, names ...string) error {
+	if path, _ := cmd.Flags().GetString("input-json"); path != "" {
+		return nil
+	}
+
+	var missing []string
+	for _, name := range names {
+		if !cmd.Flags().Changed(name) {
+			missing = append(missing, fmt.Sprintf("%q", name))
+		}
+	}
+	if len(missing) > 0 {
+		return &usageError{err: fmt.Errorf("required flag(s) %s not set, or use --input-json", strings.Join(missing, ", "))}
+	}
+	return nil
+}
+
+// decodeInput decodes a JSON or YAML document into v. Unknown fields and values of the wrong type
+// are rejected, with the line and column at which they appear.
+func decodeInput(source string, data []byte, v interface{}) error {
+	// JSON documents are YAML documents too, parsing both as YAML gives positions for both
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("failed to parse input %s: %w", source, err)
+	}
+	if len(root.Content) == 0 {
+		return fmt.Errorf("failed to parse input %s: document is empty", source)
+	}
+	doc := root.Content[0]
+
+	if err := checkInputFields(source, doc, reflect.TypeOf(v).Elem(), ""); err != nil {
+		return err
+	}
+
+	var generic interface{}
+	if err := doc.Decode(&generic); err != nil {
+		return fmt.Errorf("failed to parse input %s: %w", source, err)
+	}
+	// YAML documents are converted to JSON so that both formats share the JSON field names
+	converted, err := json.Marshal(generic)
+	if err != nil {
+		return fmt.Errorf("failed to parse input %s: %w", source, err)
+	}
+
+	if err := json.Unmarshal(converted, v); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			n := findInputNode(doc, typeErr.Field)
+			return fmt.Errorf("%s:%d:%d: invalid value for %s: expected %s, got %s", source, n.Line, n.Column, typeErr.Field, describeKind(typeErr.Type), typeErr.Value)
+		}
+		return fmt.Errorf("failed to parse input %s: %w", source, err)
+	}
+	return nil
+}
+
+// checkInputFields rejects the keys of n that are not fields of t
+func checkInputFields(source string, n *yaml.Node, t reflect.Type, path string) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t.Kind() == reflect.Struct && n.Kind == yaml.MappingNode:
+		fields := inputFields(t)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			field, ok := fields[key.Value]
+			if !ok {
+				msg := fmt.Sprintf("%s:%d:%d: unknown field %q", source, key.Line, key.Column, joinInputPath(path, key.Value))
+				if suggestion := closestField(key.Value, fields); suggestion != "" {
+					msg += fmt.Sprintf(", did you mean %q?", suggestion)
+				}
+				return errors.New(msg)
+			}
+			if err := checkInputFields(source, value, field.Type, joinInputPath(path, key.Value)); err != nil {
+				return err
+			}
+		}
+	case t.Kind() == reflect.Slice && n.Kind == yaml.SequenceNode:
+		for _, item := range n.Content {
+			if err := checkInputFields(source, item, t.Elem(), path); err != nil {
+				return err
+			}
+		}
+	}
+	// Type mismatches are reported by the JSON decoder
+	return nil
+}
+
+// inputFields returns the fields of a struct by JSON name
+func inputFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f
+	}
+	return fields
+}
+
+// findInputNode returns the node at a dotted JSON path, or the deepest node found on the way
+func findInputNode(n *yaml.Node, path string) *yaml.Node {
+	if path == "" {
+		return n
+	}
+	for _, part := range strings.Split(path, ".") {
+		if n.Kind != yaml.MappingNode {
+			return n
+		}
+		found := false
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value == part {
+				n, found = n.Content[i+1], true
+				break
+			}
+		}
+		if !found {
+			return n
+		}
+	}
+	return n
+}
+
+// closestField suggests the field a misspelled key was meant to be
+func closestField(key string, fields map[string]reflect.StructField) string {
+	best, bestDistance := "", 3
+	for name := range fields {
+		if d := editDistance(strings.ToLower(key), strings.ToLower(name)); d < bestDistance || (d == bestDistance && best != "" && name < best) {
+			best, bestDistance = name, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func describeKind(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int64, reflect.Int32:
+		return "an integer"
+	case reflect.Float64, reflect.Float32:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Slice:
+		return "a list"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	}
+	return t.String()
+}
+
+func joinInputPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// writeSkeleton writes a YAML template of template with every field commented. Comments and
+// example values come from the flag setting the field, or from inputFieldDescriptions.
+func writeSkeleton(w io.Writer, cmd *cobra.Command, template interface{}) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Input of %s --input-json, in YAML or JSON.\n", cmd.CommandPath())
+	fmt.Fprintln(&buf, "# Remove the fields that are not needed. Flags set on the command line override these values.")
+	writeSkeletonFields(&buf, cmd.Flags(), reflect.TypeOf(template), "", "")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func writeSkeletonFields(w io.Writer, flags *pflag.FlagSet, t reflect.Type, path, indent string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" || !f.IsExported() {
+			continue
+		}
+		fieldPath := joinInputPath(path, name)
+		flag := flags.Lookup(kebabCase(fieldPath))
+
+		description := inputFieldDescriptions[fieldPath]
+		if description == "" && flag != nil {
+			description = flag.Usage
+		}
+		if description != "" {
+			fmt.Fprintf(w, "%s# %s\n", indent, description)
+		}
+
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		switch ft.Kind() {
+		case reflect.Struct:
+			fmt.Fprintf(w, "%s%s:\n", indent, name)
+			writeSkeletonFields(w, flags, ft, fieldPath, indent+"  ")
+		case reflect.Slice:
+			fmt.Fprintf(w, "%s%s: []\n", indent, name)
+		case reflect.Map:
+			fmt.Fprintf(w, "%s%s: {}\n", indent, name)
+		default:
+			fmt.Fprintf(w, "%s%s: %s\n", indent, name, skeletonValue(ft, flag))
+		}
+	}
+}
+
+// skeletonValue is the default of the flag setting a field, or the zero value of its type
+func skeletonValue(t reflect.Type, flag *pflag.Flag) string {
+	value := reflect.Zero(t).Interface()
+	if flag != nil && flag.DefValue != "" && flag.DefValue != "[]" {
+		value = flag.DefValue
+	}
+	if t.Kind() == reflect.String {
+		quoted, _ := json.Marshal(fmt.Sprint(value))
+		return string(quoted)
+	}
+	return fmt.Sprint(value)
+}
+
+// kebabCase converts a JSON field path such as accessLogs.bucket to the matching flag name
+// access-logs-bucket
+func kebabCase(path string) string {
+	var b strings.Builder
+	for i, r := range path {
+		switch {
+		case r == '.':
+			b.WriteByte('-')
+		case unicode.IsUpper(r):
+			if i > 0 && path[i-1] != '.' {
+				b.WriteByte('-')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gitlab.guerraz.net/HLB/hlb-terraform-provider/hlb"
+)
+
+func TestDecodeInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    hlb.LoadBalancerCreate
+		wantErr string
+	}{
+		{
+			name:  "json",
+			input: `{"name": "web", "idleTimeout": 120, "subnets": ["subnet-a"], "launchConfig": {"minInstanceCount": 2}}`,
+			want:  hlb.LoadBalancerCreate{Name: "web", IdleTimeout: 120, Subnets: []string{"subnet-a"}, LaunchConfig: &hlb.LaunchConfig{MinInstanceCount: 2}},
+		},
+		{
+			name:  "yaml",
+			input: "name: web\nidleTimeout: 120\ntags:\n  env: prod\n",
+			want:  hlb.LoadBalancerCreate{Name: "web", IdleTimeout: 120, Tags: map[string]string{"env": "prod"}},
+		},
+		{
+			name:    "unknown field",
+			input:   "name: web\nidleTimeot: 120\n",
+			wantErr: `input.yaml:2:1: unknown field "idleTimeot", did you mean "idleTimeout"?`,
+		},
+		{
+			name:    "unknown nested field",
+			input:   `{"launchConfig": {"instanceTyp": "t3.small"}}`,
+			wantErr: `input.yaml:1:19: unknown field "launchConfig.instanceTyp", did you mean "instanceType"?`,
+		},
+		{
+			name:    "unknown field without suggestion",
+			input:   "region: us-east-1\n",
+			wantErr: `input.yaml:1:1: unknown field "region"`,
+		},
+		{
+			name:    "wrong type",
+			input:   "name: web\nidleTimeout: soon\n",
+			wantErr: "input.yaml:2:14: invalid value for idleTimeout: expected an integer, got string",
+		},
+		{
+			name:    "wrong nested type",
+			input:   "launchConfig:\n  minInstanceCount: [1]\n",
+			wantErr: "input.yaml:2:21: invalid value for launchConfig.minInstanceCount: expected an integer, got array",
+		},
+		{
+			name:    "empty document",
+			input:   "",
+			wantErr: "failed to parse input input.yaml: document is empty",
+		},
+		{
+			name:    "syntax error",
+			input:   "name: [web\n",
+			wantErr: "failed to parse input input.yaml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got hlb.LoadBalancerCreate
+			err := decodeInput("input.yaml", []byte(tt.input), &got)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("decodeInput() error = %v, want an error starting with %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeInput() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeInput() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClosestField(t *testing.T) {
+	fields := inputFields(reflect.TypeOf(hlb.LoadBalancerCreate{}))
+
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{key: "idleTimeot", want: "idleTimeout"},
+		{key: "IDLETIMEOUT", want: "idleTimeout"},
+		{key: "subnet", want: "subnets"},
+		{key: "zoneid", want: "zoneId"},
+		{key: "securityGroup", want: "securityGroups"},
+		{key: "region", want: ""},
+		{key: "timeout", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := closestField(tt.key, fields); got != tt.want {
+				t.Errorf("closestField(%q) = %q, want %q", tt.key, got, tt.want)
+			}
+		})
+	}
+}
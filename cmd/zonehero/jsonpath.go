@@ -0,0 +1,521 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// executeJSONPath writes data with a kubectl JSONPath template, such as {.items[*].id} or
+// {range .items[?(@.state=="active")]}{.id}{"\n"}{end}. Missing fields yield no result, as in
+// kubectl.
+func executeJSONPath(w io.Writer, template string, data interface{}) error {
+	nodes, err := parseJSONPath(template)
+	if err != nil {
+		return fmt.Errorf("invalid jsonpath %q: %w", template, err)
+	}
+
+	// Numbers are kept as json.Number, float64 would print large integers as 1e+06
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return fmt.Errorf("failed to encode output: %w", err)
+	}
+
+	if err := executeJSONPathNodes(w, nodes, generic); err != nil {
+		return fmt.Errorf("failed to execute jsonpath: %w", err)
+	}
+	return nil
+}
+
+// jsonPathNode is literal text, or an expression whose results are written or, for a range, used
+// as the data of the children nodes
+type jsonPathNode struct {
+	literal  string
+	steps    []jsonPathStep
+	isRange  bool
+	children []jsonPathNode
+	pos      int // position of the action in the template, for errors
+}
+
+// jsonPathStep selects values from the results of the previous step
+type jsonPathStep struct {
+	field    string          // set for .field and ['field']
+	wildcard bool            // set for .* and [*]
+	index    *int            // set for [n]
+	slice    *[2]*int        // set for [start:end], a nil bound is open
+	filter   *jsonPathFilter // set for [?(...)]
+}
+
+// jsonPathFilter keeps the list items for which the path relative to @ compares to value with op,
+// or exists when op is empty
+type jsonPathFilter struct {
+	path  []jsonPathStep
+	op    string
+	value interface{} // string, float64 or bool
+}
+
+var jsonPathOperators = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// parseJSONPath parses the literal text, {expression}, {"literal"} and {range expression}...{end}
+// actions of template
+func parseJSONPath(template string) ([]jsonPathNode, error) {
+	var stack []*jsonPathNode // enclosing ranges
+	var nodes []jsonPathNode
+	add := func(node jsonPathNode) {
+		if len(stack) > 0 {
+			stack[len(stack)-1].children = append(stack[len(stack)-1].children, node)
+		} else {
+			nodes = append(nodes, node)
+		}
+	}
+
+	for pos := 0; pos < len(template); {
+		open := strings.IndexByte(template[pos:], '{')
+		if open < 0 {
+			add(jsonPathNode{literal: template[pos:]})
+			break
+		}
+		if open > 0 {
+			add(jsonPathNode{literal: template[pos : pos+open]})
+		}
+		pos += open
+
+		end := indexUnquoted(template, pos+1, '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed action at position %d", pos)
+		}
+		action := strings.TrimSpace(template[pos+1 : end])
+
+		switch {
+		case action == "end":
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("{end} at position %d is not in range", pos)
+			}
+			rangeNode := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			add(*rangeNode)
+		case strings.HasPrefix(action, "range "):
+			steps, err := parseJSONPathSteps(strings.TrimSpace(strings.TrimPrefix(action, "range ")), pos)
+			if err != nil {
+				return nil, err
+			}
+			stack = append(stack, &jsonPathNode{steps: steps, isRange: true, pos: pos})
+		case strings.HasPrefix(action, `"`):
+			literal, err := strconv.Unquote(action)
+			if err != nil {
+				return nil, fmt.Errorf("invalid literal %s at position %d", action, pos)
+			}
+			add(jsonPathNode{literal: literal})
+		default:
+			steps, err := parseJSONPathSteps(action, pos)
+			if err != nil {
+				return nil, err
+			}
+			add(jsonPathNode{steps: steps, pos: pos})
+		}
+		pos = end + 1
+	}
+
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("range at position %d has no {end}", stack[len(stack)-1].pos)
+	}
+	return nodes, nil
+}
+
+// indexUnquoted returns the index of the first c in s from start that is not within quotes, -1 if
+// there is none
+func indexUnquoted(s string, start int, c byte) int {
+	for i := start; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\'':
+			i = skipQuoted(s, i)
+		case c:
+			return i
+		}
+	}
+	return -1
+}
+
+// skipQuoted returns the index of the quote closing the one at start, len(s) if there is none
+func skipQuoted(s string, start int) int {
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case s[start]:
+			return i
+		}
+	}
+	return len(s)
+}
+
+// parseJSONPathSteps parses an expression such as .items[0].tags['env'], pos is the position of
+// the action in the template
+func parseJSONPathSteps(expr string, pos int) ([]jsonPathStep, error) {
+	path := strings.TrimPrefix(expr, "$")
+	var steps []jsonPathStep
+
+	for len(path) > 0 {
+		switch path[0] {
+		case '.':
+			if strings.HasPrefix(path, "..") {
+				return nil, fmt.Errorf("recursive descent in %q at position %d is not supported", expr, pos)
+			}
+			path = path[1:]
+			end := strings.IndexAny(path, ".[")
+			if end < 0 {
+				end = len(path)
+			}
+			switch name := path[:end]; name {
+			case "":
+			case "*":
+				steps = append(steps, jsonPathStep{wildcard: true})
+			default:
+				steps = append(steps, jsonPathStep{field: name})
+			}
+			path = path[end:]
+		case '[':
+			end := indexClosingBracket(path)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated array in %q at position %d", expr, pos)
+			}
+			step, err := parseJSONPathSubscript(strings.TrimSpace(path[1:end]), pos)
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, step)
+			path = path[end+1:]
+		default:
+			return nil, fmt.Errorf("unexpected %q in %q at position %d, fields start with '.'", path[0], expr, pos)
+		}
+	}
+
+	return steps, nil
+}
+
+// indexClosingBracket returns the index of the ']' closing the '[' path starts with, -1 if there
+// is none
+func indexClosingBracket(path string) int {
+	depth := 0
+	for i := 0; i < len(path); i++ {
+		switch path[i] {
+		case '"', '\'':
+			i = skipQuoted(path, i)
+		case '[':
+			depth++
+		case ']':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parseJSONPathSubscript parses the content of [...]: *, 'field', an index, a slice or a filter
+func parseJSONPathSubscript(subscript string, pos int) (jsonPathStep, error) {
+	switch {
+	case subscript == "*":
+		return jsonPathStep{wildcard: true}, nil
+	case strings.HasPrefix(subscript, "?"):
+		filter, err := parseJSONPathFilter(subscript, pos)
+		if err != nil {
+			return jsonPathStep{}, err
+		}
+		return jsonPathStep{filter: filter}, nil
+	case strings.HasPrefix(subscript, "'") || strings.HasPrefix(subscript, `"`):
+		field, err := unquoteJSONPathString(subscript)
+		if err != nil {
+			return jsonPathStep{}, fmt.Errorf("invalid field name %s at position %d", subscript, pos)
+		}
+		return jsonPathStep{field: field}, nil
+	case strings.Contains(subscript, ":"):
+		bounds := strings.Split(subscript, ":")
+		if len(bounds) != 2 {
+			return jsonPathStep{}, fmt.Errorf("invalid array index [%s] at position %d, slices are [start:end]", subscript, pos)
+		}
+		var slice [2]*int
+		for i, bound := range bounds {
+			if bound = strings.TrimSpace(bound); bound == "" {
+				continue
+			}
+			n, err := strconv.Atoi(bound)
+			if err != nil {
+				return jsonPathStep{}, fmt.Errorf("invalid array index [%s] at position %d", subscript, pos)
+			}
+			slice[i] = &n
+		}
+		return jsonPathStep{slice: &slice}, nil
+	default:
+		n, err := strconv.Atoi(subscript)
+		if err != nil {
+			return jsonPathStep{}, fmt.Errorf("invalid array index [%s] at position %d", subscript, pos)
+		}
+		return jsonPathStep{index: &n}, nil
+	}
+}
+
+// parseJSONPathFilter parses ?(@.field), or ?(@.field op value) where value is a quoted string, a
+// number, true or false
+func parseJSONPathFilter(subscript string, pos int) (*jsonPathFilter, error) {
+	expr := strings.TrimSpace(strings.TrimPrefix(subscript, "?"))
+	if !strings.HasPrefix(expr, "(") || !strings.HasSuffix(expr, ")") {
+		return nil, fmt.Errorf("unterminated filter [%s] at position %d", subscript, pos)
+	}
+	expr = strings.TrimSpace(expr[1 : len(expr)-1])
+
+	left, op, right := expr, "", ""
+	for i := 0; i < len(expr) && op == ""; i++ {
+		if expr[i] == '"' || expr[i] == '\'' {
+			i = skipQuoted(expr, i)
+			continue
+		}
+		for _, candidate := range jsonPathOperators {
+			if strings.HasPrefix(expr[i:], candidate) {
+				left, op, right = strings.TrimSpace(expr[:i]), candidate, strings.TrimSpace(expr[i+len(candidate):])
+				break
+			}
+		}
+	}
+
+	if !strings.HasPrefix(left, "@") {
+		return nil, fmt.Errorf("invalid filter [%s] at position %d, filters compare a field of @", subscript, pos)
+	}
+	path, err := parseJSONPathSteps(strings.TrimPrefix(left, "@"), pos)
+	if err != nil {
+		return nil, err
+	}
+	filter := &jsonPathFilter{path: path, op: op}
+	if op == "" {
+		return filter, nil
+	}
+
+	switch {
+	case strings.HasPrefix(right, "'") || strings.HasPrefix(right, `"`):
+		if filter.value, err = unquoteJSONPathString(right); err != nil {
+			return nil, fmt.Errorf("invalid filter value %s at position %d", right, pos)
+		}
+	case right == "true" || right == "false":
+		filter.value = right == "true"
+	default:
+		if filter.value, err = strconv.ParseFloat(right, 64); err != nil {
+			return nil, fmt.Errorf("invalid filter value %q at position %d, must be a quoted string, a number, true or false", right, pos)
+		}
+	}
+	return filter, nil
+}
+
+// unquoteJSONPathString unquotes a string in single or double quotes
+func unquoteJSONPathString(s string) (string, error) {
+	if len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'' {
+		return strings.ReplaceAll(s[1:len(s)-1], `\'`, `'`), nil
+	}
+	return strconv.Unquote(s)
+}
+
+func executeJSONPathNodes(w io.Writer, nodes []jsonPathNode, data interface{}) error {
+	for _, node := range nodes {
+		if node.steps == nil && !node.isRange {
+			io.WriteString(w, node.literal)
+			continue
+		}
+
+		results, err := evaluateJSONPath(node.steps, data)
+		if err != nil {
+			return fmt.Errorf("action at position %d: %w", node.pos, err)
+		}
+
+		if node.isRange {
+			for _, item := range results {
+				if err := executeJSONPathNodes(w, node.children, item); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		values := make([]string, 0, len(results))
+		for _, result := range results {
+			values = append(values, formatJSONPathValue(result))
+		}
+		io.WriteString(w, strings.Join(values, " "))
+	}
+	return nil
+}
+
+func evaluateJSONPath(steps []jsonPathStep, data interface{}) ([]interface{}, error) {
+	values := []interface{}{data}
+	for _, step := range steps {
+		var next []interface{}
+		for _, value := range values {
+			selected, err := step.selectFrom(value)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, selected...)
+		}
+		values = next
+	}
+	return values, nil
+}
+
+// selectFrom returns the values step selects from value. Fields missing from the API response, and
+// steps applied to null values, yield no result.
+func (step jsonPathStep) selectFrom(value interface{}) ([]interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		switch {
+		case step.wildcard:
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			values := make([]interface{}, 0, len(keys))
+			for _, key := range keys {
+				values = append(values, v[key])
+			}
+			return values, nil
+		case step.filter != nil || step.index != nil || step.slice != nil:
+			return nil, errors.New("cannot index an object, use .field")
+		}
+		if field, ok := v[step.field]; ok {
+			return []interface{}{field}, nil
+		}
+		return nil, nil
+	case []interface{}:
+		switch {
+		case step.wildcard:
+			return v, nil
+		case step.index != nil:
+			index := *step.index
+			if index < 0 {
+				index += len(v)
+			}
+			if index < 0 || index >= len(v) {
+				return nil, fmt.Errorf("array index %d is out of bounds, the array has %d items", *step.index, len(v))
+			}
+			return []interface{}{v[index]}, nil
+		case step.slice != nil:
+			start, end := sliceBound(step.slice[0], 0, len(v)), sliceBound(step.slice[1], len(v), len(v))
+			if start >= end {
+				return nil, nil
+			}
+			return v[start:end], nil
+		case step.filter != nil:
+			var values []interface{}
+			for _, item := range v {
+				match, err := step.filter.matches(item)
+				if err != nil {
+					return nil, err
+				}
+				if match {
+					values = append(values, item)
+				}
+			}
+			return values, nil
+		}
+		return nil, fmt.Errorf("cannot read field %q of an array, use [*] to read it from every item", step.field)
+	default:
+		if step.field != "" {
+			return nil, fmt.Errorf("cannot read field %q of %v", step.field, v)
+		}
+		return nil, fmt.Errorf("cannot index %v", v)
+	}
+}
+
+// sliceBound returns bound within [0, length], counting negative bounds from the end
+func sliceBound(bound *int, defaultValue, length int) int {
+	if bound == nil {
+		return defaultValue
+	}
+	n := *bound
+	if n < 0 {
+		n += length
+	}
+	return min(max(n, 0), length)
+}
+
+func (f *jsonPathFilter) matches(item interface{}) (bool, error) {
+	values, err := evaluateJSONPath(f.path, item)
+	if err != nil || len(values) == 0 || values[0] == nil {
+		return false, err
+	}
+	if f.op == "" {
+		return true, nil
+	}
+
+	var cmp int
+	switch left := values[0].(type) {
+	case string:
+		right, ok := f.value.(string)
+		if !ok {
+			return f.op == "!=", nil
+		}
+		cmp = strings.Compare(left, right)
+	case json.Number:
+		right, ok := f.value.(float64)
+		if !ok {
+			return f.op == "!=", nil
+		}
+		n, _ := left.Float64()
+		switch {
+		case n < right:
+			cmp = -1
+		case n > right:
+			cmp = 1
+		}
+	case bool:
+		right, ok := f.value.(bool)
+		if !ok || (f.op != "==" && f.op != "!=") {
+			return f.op == "!=", nil
+		}
+		if left != right {
+			cmp = 1
+		}
+	default:
+		return f.op == "!=", nil
+	}
+
+	switch f.op {
+	case "==":
+		return cmp == 0, nil
+	case "!=":
+		return cmp != 0, nil
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+func formatJSONPathValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	case map[string]interface{}, []interface{}:
+		raw, _ := json.Marshal(v)
+		return string(raw)
+	default:
+		return fmt.Sprint(v)
+	}
+}
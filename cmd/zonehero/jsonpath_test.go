@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gitlab.guerraz.net/HLB/hlb-terraform-provider/hlb"
+)
+
+func TestExecuteJSONPath(t *testing.T) {
+	data := map[string]interface{}{
+		"items": []hlb.LoadBalancer{
+			{ID: "lb-1", Name: "web", State: hlb.LBStateActive, IdleTimeout: 60, ClientKeepAlive: 1000000, Tags: map[string]string{"env": "prod"}},
+			{ID: "lb-2", Name: "api", State: hlb.LBStateFailed, IdleTimeout: 120, Internal: true},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  string
+	}{
+		{name: "field", template: "{.items[0].name}", want: "web"},
+		{name: "literal text", template: "name={.items[1].name}", want: "name=api"},
+		{name: "wildcard", template: "{.items[*].id}", want: "lb-1 lb-2"},
+		{name: "negative index", template: "{.items[-1].id}", want: "lb-2"},
+		{name: "slice", template: "{.items[0:1].id}", want: "lb-1"},
+		{name: "open slice", template: "{.items[-1:].id}", want: "lb-2"},
+		{name: "root", template: "{$.items[1].name}", want: "api"},
+		{name: "quoted field", template: "{.items[0].tags['env']}", want: "prod"},
+		{name: "map wildcard", template: "{.items[0].tags.*}", want: "prod"},
+		{name: "object", template: "{.items[0].tags}", want: `{"env":"prod"}`},
+		{name: "integers are printed as such", template: "{.items[0].clientKeepAlive}", want: "1000000"},
+		{name: "map field", template: "{.items[0].tags.env}", want: "prod"},
+		{name: "filter", template: `{.items[?(@.state=="failed")].id}`, want: "lb-2"},
+		{name: "numeric filter", template: "{.items[?(@.idleTimeout>60)].name}", want: "api"},
+		{name: "inequality filter", template: "{.items[?(@.name!='web')].id}", want: "lb-2"},
+		{name: "boolean filter", template: "{.items[?(@.internal==true)].id}", want: "lb-2"},
+		{name: "existence filter", template: "{.items[?(@.tags.env)].id}", want: "lb-1"},
+		{name: "quoted brackets in filter", template: `{.items[?(@.name=="]")].id}`, want: ""},
+		{name: "range", template: `{range .items[*]}{.id}{"\t"}{.name}{"\n"}{end}`, want: "lb-1\tweb\nlb-2\tapi\n"},
+		{name: "range with filter", template: `{range .items[?(@.state=="active")]}{.id}{"\n"}{end}`, want: "lb-1\n"},
+		{name: "missing field", template: "{.items[1].tags.env}", want: ""},
+		{name: "unclosed expression", template: "id={.items[0].id", wantErr: "unclosed action at position 3"},
+		{name: "unterminated filter", template: "{.items[?(@.state]}", wantErr: "unterminated filter"},
+		{name: "unterminated subscript", template: "{.items[}", wantErr: "unterminated array"},
+		{name: "end without range", template: "{.items[0].id}{end}", wantErr: "{end} at position 14 is not in range"},
+		{name: "range without end", template: "{range .items[*]}{.id}", wantErr: "range at position 0 has no {end}"},
+		{name: "invalid index", template: "{.items[a:b]}", wantErr: "invalid array index"},
+		{name: "invalid filter value", template: "{.items[?(@.state==active)]}", wantErr: "invalid filter value"},
+		{name: "recursive descent", template: "{..id}", wantErr: "not supported"},
+		{name: "index out of range", template: "{.items[5].id}", wantErr: "out of bounds"},
+		{name: "field of a scalar", template: "{.items[0].name.first}", wantErr: `cannot read field "first"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := executeJSONPath(&buf, tt.template, data)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("executeJSONPath() error = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("executeJSONPath() error = %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("executeJSONPath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
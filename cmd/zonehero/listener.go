@@ -1,10 +1,12 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
+	"io"
 	"os"
-	"text/tabwriter"
+	"strconv"
+	"time"
 
 	"github.com/spf13/cobra"
 	"gitlab.guerraz.net/HLB/hlb-terraform-provider/hlb"
@@ -32,19 +34,23 @@ func init() {
 	createListenerCmd.Flags().String("certificate-secrets-name", "", "Certificate secrets name (for HTTPS)")
 	createListenerCmd.Flags().String("alpn-policy", "", "ALPN policy")
 	createListenerCmd.Flags().Bool("enable-deletion-protection", false, "Enable deletion protection")
-	createListenerCmd.Flags().String("input-json", "", "JSON file containing listener configuration")
+	createListenerCmd.Flags().Float64("overprovisioning-factor", 1.1, "Traffic an instance can receive relative to the average when cross-zone load balancing is 'avoid' (>= 1.0)")
+	createListenerCmd.Flags().String("client-token", "", "Token making the creation safe to repeat: a single listener is created per token (default: random)")
+	addInputFlags(createListenerCmd, "File containing the listener configuration", hlb.ListenerCreate{})
 	createListenerCmd.MarkFlagRequired("load-balancer-id")
-	createListenerCmd.MarkFlagRequired("port")
-	createListenerCmd.MarkFlagRequired("protocol")
-	createListenerCmd.MarkFlagRequired("target-group-arn")
 
 	// Update Listener Flags
 	updateListenerCmd.Flags().String("load-balancer-id", "", "ID of the load balancer")
 	updateListenerCmd.Flags().String("listener-id", "", "ID of the listener")
 	updateListenerCmd.Flags().Int("port", 0, "Port number")
-	updateListenerCmd.Flags().String("protocol", "", "Protocol (HTTP/HTTPS)")
 	updateListenerCmd.Flags().String("target-group-arn", "", "Target group ARN")
-	updateListenerCmd.Flags().String("input-json", "", "JSON file containing update configuration")
+	updateListenerCmd.Flags().String("certificate-secrets-name", "", "Certificate secrets name (for HTTPS), empty to clear")
+	updateListenerCmd.Flags().String("alpn-policy", "", "ALPN policy, empty to clear")
+	updateListenerCmd.Flags().Bool("enable-deletion-protection", false, "Enable deletion protection")
+	updateListenerCmd.Flags().Bool("no-deletion-protection", false, "Disable deletion protection")
+	updateListenerCmd.Flags().Float64("overprovisioning-factor", 0, "Traffic an instance can receive relative to the average when cross-zone load balancing is 'avoid' (>= 1.0)")
+	addInputFlags(updateListenerCmd, "File containing the update configuration", hlb.ListenerUpdate{})
+	updateListenerCmd.MarkFlagsMutuallyExclusive("enable-deletion-protection", "no-deletion-protection")
 	updateListenerCmd.MarkFlagRequired("load-balancer-id")
 	updateListenerCmd.MarkFlagRequired("listener-id")
 
@@ -59,6 +65,7 @@ func init() {
 	deleteListenerCmd.Flags().String("listener-id", "", "ID of the listener")
 	deleteListenerCmd.MarkFlagRequired("load-balancer-id")
 	deleteListenerCmd.MarkFlagRequired("listener-id")
+	addDeleteFlags(deleteListenerCmd, false)
 }
 
 var listListenersCmd = &cobra.Command{
@@ -79,24 +86,15 @@ var listListenersCmd = &cobra.Command{
 			return err
 		}
 
-		if output == "json" {
-			return json.NewEncoder(os.Stdout).Encode(map[string]interface{}{
-				"items":     listeners,
-				"nextToken": newNextToken,
-			})
-		}
-
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-		fmt.Fprintln(w, "ID\tPORT\tPROTOCOL\tTARGET GROUP")
-		for _, l := range listeners {
-			fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", l.ID, l.Port, l.Protocol, l.TargetGroupARN)
-		}
-		w.Flush()
-
-		if newNextToken != "" {
-			fmt.Printf("\nUse --next-token '%s' to get the next page\n", newNextToken)
+		err = outputPrinter.printList(map[string]interface{}{
+			"items":     listeners,
+			"nextToken": newNextToken,
+		}, listenerTable(listeners))
+		if err != nil {
+			return err
 		}
 
+		outputPrinter.printNextToken(newNextToken)
 		return nil
 	},
 }
@@ -118,14 +116,7 @@ var getListenerCmd = &cobra.Command{
 			return err
 		}
 
-		if output == "json" {
-			return json.NewEncoder(os.Stdout).Encode(listener)
-		}
-
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-		fmt.Fprintln(w, "ID\tPORT\tPROTOCOL\tTARGET GROUP")
-		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", listener.ID, listener.Port, listener.Protocol, listener.TargetGroupARN)
-		return w.Flush()
+		return outputPrinter.printObject(listener, describeListener(listener))
 	},
 }
 
@@ -133,52 +124,33 @@ var createListenerCmd = &cobra.Command{
 	Use:   "create-listener",
 	Short: "Create a new listener",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		client, err := createClient(cmd.Context())
-		if err != nil {
+		lbID, _ := cmd.Flags().GetString("load-balancer-id")
+		if err := requireFlagsWithoutInput(cmd, "port", "protocol", "target-group-arn"); err != nil {
 			return err
 		}
 
-		lbID, _ := cmd.Flags().GetString("load-balancer-id")
 		var input hlb.ListenerCreate
+		fromFile, err := readInput(cmd, &input)
+		if err != nil {
+			return err
+		}
+		applyListenerCreateFlags(cmd, &input, fromFile)
 
-		// Check if JSON input file is provided
-		if inputJSON, _ := cmd.Flags().GetString("input-json"); inputJSON != "" {
-			data, err := os.ReadFile(inputJSON)
-			if err != nil {
-				return fmt.Errorf("failed to read input JSON file: %w", err)
-			}
-			if err := json.Unmarshal(data, &input); err != nil {
-				return fmt.Errorf("failed to parse input JSON: %w", err)
-			}
-		} else {
-			port, _ := cmd.Flags().GetInt("port")
-			protocol, _ := cmd.Flags().GetString("protocol")
-			targetGroupARN, _ := cmd.Flags().GetString("target-group-arn")
-			certSecretsName, _ := cmd.Flags().GetString("certificate-secrets-name")
-			alpnPolicy, _ := cmd.Flags().GetString("alpn-policy")
-			enableDeletionProtection, _ := cmd.Flags().GetBool("enable-deletion-protection")
-
-			input = hlb.ListenerCreate{
-				Port:                     port,
-				Protocol:                 protocol,
-				TargetGroupARN:           targetGroupARN,
-				CertificateSecretsName:   certSecretsName,
-				ALPNPolicy:               alpnPolicy,
-				EnableDeletionProtection: enableDeletionProtection,
-			}
+		if err := validateListenerCreate(&input); err != nil {
+			return err
 		}
 
-		listener, err := client.CreateListener(cmd.Context(), lbID, &input)
+		client, err := createClient(cmd.Context())
 		if err != nil {
 			return err
 		}
 
-		if output == "json" {
-			return json.NewEncoder(os.Stdout).Encode(listener)
+		listener, err := client.CreateListener(cmd.Context(), lbID, &input)
+		if err != nil {
+			return resumableCreateError(err, "listener", input.ClientToken)
 		}
 
-		fmt.Printf("Created listener: %s (Port: %d)\n", listener.ID, listener.Port)
-		return nil
+		return outputPrinter.printMessage(listener, "Created listener: %s (Port: %d)", listener.ID, listener.Port)
 	},
 }
 
@@ -194,26 +166,22 @@ var updateListenerCmd = &cobra.Command{
 		lbID, _ := cmd.Flags().GetString("load-balancer-id")
 		listenerID, _ := cmd.Flags().GetString("listener-id")
 		var input hlb.ListenerUpdate
+		if _, err := readInput(cmd, &input); err != nil {
+			return err
+		}
+		applyListenerUpdateFlags(cmd, &input)
 
-		// Check if JSON input file is provided
-		if inputJSON, _ := cmd.Flags().GetString("input-json"); inputJSON != "" {
-			data, err := os.ReadFile(inputJSON)
+		// Validate the protocol, certificate and ALPN combination the listener will end up with
+		if input.Port != nil || input.Protocol != nil || input.CertificateSecretsName != nil || input.ALPNPolicy != nil || input.OverprovisioningFactor != nil {
+			current, err := client.GetListener(cmd.Context(), lbID, listenerID)
 			if err != nil {
-				return fmt.Errorf("failed to read input JSON file: %w", err)
-			}
-			if err := json.Unmarshal(data, &input); err != nil {
-				return fmt.Errorf("failed to parse input JSON: %w", err)
+				return err
 			}
-		} else {
-			if port, _ := cmd.Flags().GetInt("port"); port != 0 {
-				input.Port = &port
-			}
-			if protocol, _ := cmd.Flags().GetString("protocol"); protocol != "" {
-				input.Protocol = &protocol
-			}
-			if targetGroupARN, _ := cmd.Flags().GetString("target-group-arn"); targetGroupARN != "" {
-				input.TargetGroupARN = &targetGroupARN
+			if err := validateListenerUpdate(current, &input); err != nil {
+				return err
 			}
+			// The update is only valid for the version that was validated
+			input.IfMatch = current.ETag()
 		}
 
 		listener, err := client.UpdateListener(cmd.Context(), lbID, listenerID, &input)
@@ -221,18 +189,15 @@ var updateListenerCmd = &cobra.Command{
 			return err
 		}
 
-		if output == "json" {
-			return json.NewEncoder(os.Stdout).Encode(listener)
-		}
-
-		fmt.Printf("Updated listener: %s (Port: %d)\n", listener.ID, listener.Port)
-		return nil
+		return outputPrinter.printMessage(listener, "Updated listener: %s (Port: %d)", listener.ID, listener.Port)
 	},
 }
 
 var deleteListenerCmd = &cobra.Command{
 	Use:   "delete-listener",
 	Short: "Delete a listener",
+	Long: `Delete a listener. A summary of the listener is shown and confirmation is asked for, unless
+--yes is set. Use --disable-protection to delete a listener with deletion protection enabled.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		client, err := createClient(cmd.Context())
 		if err != nil {
@@ -241,17 +206,168 @@ var deleteListenerCmd = &cobra.Command{
 
 		lbID, _ := cmd.Flags().GetString("load-balancer-id")
 		listenerID, _ := cmd.Flags().GetString("listener-id")
+		opts := deleteOptionsFromFlags(cmd)
+
+		listener, err := client.GetListener(cmd.Context(), lbID, listenerID)
+		if err != nil {
+			return err
+		}
+		// Listeners returned by the API may omit the load balancer they belong to
+		listener.LoadBalancerID = lbID
+		if listener.EnableDeletionProtection && !opts.disableProtection {
+			return fmt.Errorf("listener %s (%d/%s) has deletion protection enabled, use --disable-protection to turn it off and delete it", listener.ID, listener.Port, listener.Protocol)
+		}
 
-		if err := client.DeleteListener(cmd.Context(), lbID, listenerID); err != nil {
+		describeListener(listener)(os.Stderr)
+		if err := confirm(cmd, fmt.Sprintf("Delete listener %d/%s?", listener.Port, listener.Protocol)); err != nil {
 			return err
 		}
 
-		if output == "json" {
-			fmt.Println("{\"status\": \"deleted\"}")
-		} else {
-			fmt.Printf("Deleted listener: %s\n", listenerID)
+		if err := opts.deleteListener(cmd.Context(), client, listener); err != nil {
+			return err
 		}
 
-		return nil
+		return outputPrinter.printMessage(map[string]string{"status": "deleted"}, "Deleted listener: %s", listenerID)
 	},
 }
+
+// applyListenerCreateFlags sets input from the create-listener flags. When input was read from a
+// file, only the flags set on the command line override its values.
+func applyListenerCreateFlags(cmd *cobra.Command, input *hlb.ListenerCreate, fromFile bool) {
+	flags := cmd.Flags()
+	use := func(name string) bool {
+		return !fromFile || flags.Changed(name)
+	}
+
+	// The token is chosen here so that it can be printed if the outcome of the creation is unknown
+	input.ClientToken, _ = flags.GetString("client-token")
+	if input.ClientToken == "" {
+		input.ClientToken = hlb.NewClientToken()
+	}
+
+	if use("port") {
+		input.Port, _ = flags.GetInt("port")
+	}
+	if use("protocol") {
+		input.Protocol, _ = flags.GetString("protocol")
+	}
+	if use("target-group-arn") {
+		input.TargetGroupARN, _ = flags.GetString("target-group-arn")
+	}
+	if use("certificate-secrets-name") {
+		input.CertificateSecretsName, _ = flags.GetString("certificate-secrets-name")
+	}
+	if use("alpn-policy") {
+		input.ALPNPolicy, _ = flags.GetString("alpn-policy")
+	}
+	if use("enable-deletion-protection") {
+		input.EnableDeletionProtection, _ = flags.GetBool("enable-deletion-protection")
+	}
+	if use("overprovisioning-factor") {
+		input.OverprovisioningFactor, _ = flags.GetFloat64("overprovisioning-factor")
+	}
+}
+
+// applyListenerUpdateFlags sets the fields of an update request whose update-listener flags were
+// explicitly set, only these and the fields of the input file are sent
+func applyListenerUpdateFlags(cmd *cobra.Command, input *hlb.ListenerUpdate) {
+	flags := cmd.Flags()
+
+	if flags.Changed("port") {
+		v, _ := flags.GetInt("port")
+		input.Port = &v
+	}
+	if flags.Changed("target-group-arn") {
+		v, _ := flags.GetString("target-group-arn")
+		input.TargetGroupARN = &v
+	}
+	if flags.Changed("certificate-secrets-name") {
+		v, _ := flags.GetString("certificate-secrets-name")
+		input.CertificateSecretsName = &v
+	}
+	if flags.Changed("alpn-policy") {
+		v, _ := flags.GetString("alpn-policy")
+		input.ALPNPolicy = &v
+	}
+	if flags.Changed("overprovisioning-factor") {
+		v, _ := flags.GetFloat64("overprovisioning-factor")
+		input.OverprovisioningFactor = &v
+	}
+	if flags.Changed("enable-deletion-protection") {
+		v, _ := flags.GetBool("enable-deletion-protection")
+		input.EnableDeletionProtection = &v
+	}
+	if flags.Changed("no-deletion-protection") {
+		v, _ := flags.GetBool("no-deletion-protection")
+		v = !v
+		input.EnableDeletionProtection = &v
+	}
+}
+
+// listenerTable renders listeners in the text and wide formats
+func listenerTable(listeners []hlb.Listener) *table {
+	t := &table{
+		headers:     []string{"ID", "PORT", "PROTOCOL", "TARGET GROUP"},
+		wideHeaders: []string{"ALPN POLICY", "CERTIFICATE", "OVERPROVISIONING", "DELETION PROTECTION"},
+	}
+	for _, l := range listeners {
+		t.rows = append(t.rows, []string{l.ID, strconv.Itoa(l.Port), l.Protocol, l.TargetGroupARN})
+		t.wideRows = append(t.wideRows, []string{
+			orNone(l.ALPNPolicy),
+			orNone(l.CertificateSecretsName),
+			strconv.FormatFloat(l.OverprovisioningFactor, 'g', -1, 64),
+			strconv.FormatBool(l.EnableDeletionProtection),
+		})
+	}
+	return t
+}
+
+// describeListener renders the detailed text view of a listener
+func describeListener(l *hlb.Listener) describer {
+	return func(w io.Writer) {
+		d := newDescribeWriter(w)
+		defer d.flush()
+
+		d.field("ID", l.ID)
+		d.field("Load Balancer ID", l.LoadBalancerID)
+		d.field("Port", l.Port)
+		d.field("Protocol", l.Protocol)
+		d.field("Target Group", l.TargetGroupARN)
+		d.field("Certificate Secrets Name", orNone(l.CertificateSecretsName))
+		d.field("ALPN Policy", orNone(l.ALPNPolicy))
+		d.field("Overprovisioning Factor", l.OverprovisioningFactor)
+		d.field("Deletion Protection", l.EnableDeletionProtection)
+		d.field("Created At", l.CreatedAt.Format(time.RFC3339))
+		d.field("Updated At", l.UpdatedAt.Format(time.RFC3339))
+	}
+}
+
+// listAllListeners pages through ListListeners and returns every listener of a load balancer
+func listAllListeners(ctx context.Context, client *hlb.Client, loadBalancerID string) ([]hlb.Listener, error) {
+	var all []hlb.Listener
+	nextToken := ""
+	for {
+		page, token, err := client.ListListeners(ctx, loadBalancerID, 100, nextToken)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if token == "" {
+			return all, nil
+		}
+		nextToken = token
+	}
+}
+
+// listenerCreateFromLive returns the create request reproducing the settings of l
+func listenerCreateFromLive(l *hlb.Listener) *hlb.ListenerCreate {
+	return &hlb.ListenerCreate{
+		ALPNPolicy:               l.ALPNPolicy,
+		CertificateSecretsName:   l.CertificateSecretsName,
+		EnableDeletionProtection: l.EnableDeletionProtection,
+		OverprovisioningFactor:   l.OverprovisioningFactor,
+		Port:                     l.Port,
+		Protocol:                 l.Protocol,
+		TargetGroupARN:           l.TargetGroupARN,
+	}
+}
@@ -1,10 +1,14 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"os"
-	"text/tabwriter"
+	"io"
+	"maps"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"gitlab.guerraz.net/HLB/hlb-terraform-provider/hlb"
@@ -21,10 +25,10 @@ func init() {
 	// List Load Balancers Flags
 	listLoadBalancersCmd.Flags().Int("limit", 20, "Maximum number of items to return")
 	listLoadBalancersCmd.Flags().String("next-token", "", "Token for pagination")
+	addSelectorFlags(listLoadBalancersCmd)
 
 	// Create Load Balancer Flags
 	createLoadBalancerCmd.Flags().BoolP("internal", "i", false, "Whether the load balancer is internal")
-	createLoadBalancerCmd.Flags().String("input-json", "", "JSON file containing load balancer configuration")
 	createLoadBalancerCmd.Flags().String("zone-id", "", "Route53 zone ID in which to create the records for the load balancer")
 	createLoadBalancerCmd.Flags().String("zone-name", "", "Route53 zone name in which to create the records for the load balancer")
 	createLoadBalancerCmd.Flags().StringP("ec2-iam-role", "", "lb-standard", "EC2 IAM role to assign to load balancer instances")
@@ -32,16 +36,26 @@ func init() {
 	createLoadBalancerCmd.Flags().StringP("name", "n", "", "Name of the load balancer")
 	createLoadBalancerCmd.Flags().StringSliceP("security-groups", "g", []string{}, "Security groups for the load balancer")
 	createLoadBalancerCmd.Flags().StringSliceP("subnets", "s", []string{}, "Subnets for the load balancer")
-	createLoadBalancerCmd.MarkFlagRequired("name")
-	createLoadBalancerCmd.MarkFlagRequired("subnets")
-	createLoadBalancerCmd.MarkFlagRequired("zone-id")
-	createLoadBalancerCmd.MarkFlagRequired("zone-name")
+	createLoadBalancerCmd.Flags().String("name-prefix", "", "Creates a unique name beginning with the specified prefix (1-6 characters), instead of --name")
+	createLoadBalancerCmd.MarkFlagsMutuallyExclusive("name", "name-prefix")
+	addLoadBalancerSettingsFlags(createLoadBalancerCmd)
+	createLoadBalancerCmd.Flags().Int("idle-timeout", 60, "Time in seconds that a connection is allowed to be idle (1-4000)")
+	createLoadBalancerCmd.Flags().Int("client-keep-alive", 3600, "Time in seconds to keep client connections alive (60-604800)")
+	createLoadBalancerCmd.Flags().Int("connection-draining-timeout", 10, "Connection draining time in minutes for load balancer nodes (0-120)")
+	createLoadBalancerCmd.Flags().String("enable-cross-zone-load-balancing", hlb.LBCrossAZPolicyAvoid, "Cross-zone load balancing mode: full, avoid or off")
+	createLoadBalancerCmd.Flags().String("xff-header-processing-mode", "append", "X-Forwarded-For header processing mode: append, preserve or remove")
+	createLoadBalancerCmd.Flags().Bool("enable-http2", true, "Enable HTTP/2")
+	createLoadBalancerCmd.Flags().Bool("enable-deletion-protection", false, "Enable deletion protection")
+	createLoadBalancerCmd.Flags().Bool("preserve-host-header", false, "Preserve the Host header in forwarded requests")
+	createLoadBalancerCmd.Flags().String("preferred-maintenance-window", "", "Preferred maintenance window in UTC, e.g. 'mon-fri,02:00-04:00'")
+	createLoadBalancerCmd.Flags().String("client-token", "", "Token making the creation safe to repeat: a single load balancer is created per token (default: random)")
+	addInputFlags(createLoadBalancerCmd, "File containing the load balancer configuration", hlb.LoadBalancerCreate{})
 
 	// Update Load Balancer Flags
 	updateLoadBalancerCmd.Flags().String("id", "", "ID of the load balancer to update")
-	updateLoadBalancerCmd.Flags().String("name", "", "New name for the load balancer")
-	updateLoadBalancerCmd.Flags().String("input-json", "", "JSON file containing update configuration")
-	updateLoadBalancerCmd.MarkFlagRequired("id")
+	addLoadBalancerUpdateFlags(updateLoadBalancerCmd)
+	addInputFlags(updateLoadBalancerCmd, "File containing the update configuration", hlb.LoadBalancerUpdate{})
+	addBulkSelectionFlags(updateLoadBalancerCmd)
 
 	// Get Load Balancer Flags
 	getLoadBalancerCmd.Flags().String("id", "", "ID of the load balancer to get")
@@ -49,44 +63,82 @@ func init() {
 
 	// Delete Load Balancer Flags
 	deleteLoadBalancerCmd.Flags().String("id", "", "ID of the load balancer to delete")
-	deleteLoadBalancerCmd.MarkFlagRequired("id")
+	addBulkSelectionFlags(deleteLoadBalancerCmd)
+	addDeleteFlags(deleteLoadBalancerCmd, true)
+}
+
+// addLoadBalancerSettingsFlags registers the flags for nested and map settings shared by the
+// create and update commands
+func addLoadBalancerSettingsFlags(cmd *cobra.Command) {
+	cmd.Flags().String("access-logs-bucket", "", "S3 bucket in which to store access logs")
+	cmd.Flags().String("access-logs-prefix", "", "S3 prefix under which to store access logs")
+	cmd.Flags().Bool("access-logs-enabled", false, "Enable access logs")
+	cmd.Flags().String("launch-config", "", "Launch configuration, e.g. 'instance-type=c7g.large,min=2,max=6,target-cpu=50'")
+	cmd.Flags().StringArray("tag", []string{}, "Tag to assign to the load balancer in the format key=value (can be repeated)")
+}
+
+// addLoadBalancerUpdateFlags registers the flags of the settings that update-load-balancer changes
+func addLoadBalancerUpdateFlags(cmd *cobra.Command) {
+	cmd.Flags().String("name", "", "New name for the load balancer")
+	cmd.Flags().String("ec2-iam-role", "", "EC2 IAM role to assign to load balancer instances")
+	cmd.Flags().StringSlice("security-groups", []string{}, "Security groups for the load balancer")
+	addLoadBalancerSettingsFlags(cmd)
+	cmd.Flags().Int("idle-timeout", 0, "Time in seconds that a connection is allowed to be idle (1-4000)")
+	cmd.Flags().Int("client-keep-alive", 0, "Time in seconds to keep client connections alive (60-604800)")
+	cmd.Flags().Int("connection-draining-timeout", 0, "Connection draining time in minutes for load balancer nodes (0-120)")
+	cmd.Flags().String("enable-cross-zone-load-balancing", "", "Cross-zone load balancing mode: full, avoid or off")
+	cmd.Flags().String("xff-header-processing-mode", "", "X-Forwarded-For header processing mode: append, preserve or remove")
+	cmd.Flags().Bool("enable-http2", false, "Enable HTTP/2")
+	cmd.Flags().Bool("enable-deletion-protection", false, "Enable deletion protection")
+	cmd.Flags().Bool("preserve-host-header", false, "Preserve the Host header in forwarded requests")
+	cmd.Flags().String("preferred-maintenance-window", "", "Preferred maintenance window in UTC, e.g. 'mon-fri,02:00-04:00', empty to clear")
 }
 
 var listLoadBalancersCmd = &cobra.Command{
 	Use:   "list-load-balancers",
 	Short: "List all load balancers",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if watch, _ := cmd.Flags().GetBool("watch"); watch {
+			return runWatch(cmd)
+		}
+
 		client, err := createClient(cmd.Context())
 		if err != nil {
 			return err
 		}
 
-		limit, _ := cmd.Flags().GetInt("limit")
-		nextToken, _ := cmd.Flags().GetString("next-token")
-
-		loadBalancers, newNextToken, err := client.ListLoadBalancers(cmd.Context(), limit, nextToken)
+		f, err := filterFromFlags(cmd)
 		if err != nil {
 			return err
 		}
 
-		if output == "json" {
-			return json.NewEncoder(os.Stdout).Encode(map[string]interface{}{
-				"items":     loadBalancers,
-				"nextToken": newNextToken,
-			})
+		// Filters apply to every page, so list everything rather than a single page
+		if f != nil {
+			all, err := listAllLoadBalancers(cmd.Context(), client)
+			if err != nil {
+				return err
+			}
+			loadBalancers := f.filter(all)
+			return outputPrinter.printList(map[string]interface{}{"items": loadBalancers}, loadBalancerTable(loadBalancers))
 		}
 
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-		fmt.Fprintln(w, "ID\tNAME\tDNS NAME\tSTATE")
-		for _, lb := range loadBalancers {
-			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", lb.ID, lb.Name, lb.DNSName, lb.State)
+		limit, _ := cmd.Flags().GetInt("limit")
+		nextToken, _ := cmd.Flags().GetString("next-token")
+
+		loadBalancers, newNextToken, err := client.ListLoadBalancers(cmd.Context(), limit, nextToken)
+		if err != nil {
+			return err
 		}
-		w.Flush()
 
-		if newNextToken != "" {
-			fmt.Printf("\nUse --next-token '%s' to get the next page\n", newNextToken)
+		err = outputPrinter.printList(map[string]interface{}{
+			"items":     loadBalancers,
+			"nextToken": newNextToken,
+		}, loadBalancerTable(loadBalancers))
+		if err != nil {
+			return err
 		}
 
+		outputPrinter.printNextToken(newNextToken)
 		return nil
 	},
 }
@@ -106,14 +158,7 @@ var getLoadBalancerCmd = &cobra.Command{
 			return err
 		}
 
-		if output == "json" {
-			return json.NewEncoder(os.Stdout).Encode(lb)
-		}
-
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-		fmt.Fprintln(w, "ID\tNAME\tDNS NAME\tSTATE")
-		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", lb.ID, lb.Name, lb.DNSName, lb.State)
-		return w.Flush()
+		return outputPrinter.printObject(lb, describeLoadBalancer(lb))
 	},
 }
 
@@ -121,121 +166,506 @@ var createLoadBalancerCmd = &cobra.Command{
 	Use:   "create-load-balancer",
 	Short: "Create a new load balancer",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		client, err := createClient(cmd.Context())
-		if err != nil {
+		// The name is checked by validateLoadBalancerCreate, --name-prefix can replace it
+		if err := requireFlagsWithoutInput(cmd, "subnets", "zone-id", "zone-name"); err != nil {
 			return err
 		}
 
 		var input hlb.LoadBalancerCreate
+		fromFile, err := readInput(cmd, &input)
+		if err != nil {
+			return err
+		}
+		if err := applyLoadBalancerCreateFlags(cmd, &input, fromFile); err != nil {
+			return err
+		}
 
-		// Check if JSON input file is provided
-		if inputJSON, _ := cmd.Flags().GetString("input-json"); inputJSON != "" {
-			data, err := os.ReadFile(inputJSON)
-			if err != nil {
-				return fmt.Errorf("failed to read input JSON file: %w", err)
-			}
-			if err := json.Unmarshal(data, &input); err != nil {
-				return fmt.Errorf("failed to parse input JSON: %w", err)
-			}
-		} else {
-			// Get values from flags
-			name, _ := cmd.Flags().GetString("name")
-			internal, _ := cmd.Flags().GetBool("internal")
-			subnets, _ := cmd.Flags().GetStringSlice("subnets")
-			securityGroups, _ := cmd.Flags().GetStringSlice("security-groups")
-			ipAddresstype, _ := cmd.Flags().GetString("ip-address-type")
-			zoneId, _ := cmd.Flags().GetString("zone-id")
-			zoneName, _ := cmd.Flags().GetString("zone-name")
-			ec2IamRole, _ := cmd.Flags().GetString("ec2-iam-role")
-
-			input = hlb.LoadBalancerCreate{
-				Ec2IamRole:     ec2IamRole,
-				Name:           name,
-				Internal:       internal,
-				IPAddressType:  ipAddresstype,
-				Subnets:        subnets,
-				SecurityGroups: securityGroups,
-				ZoneID:         zoneId,
-				ZoneName:       zoneName,
-			}
+		if err := validateLoadBalancerCreate(&input); err != nil {
+			return err
 		}
 
-		lb, err := client.CreateLoadBalancer(cmd.Context(), &input)
+		client, err := createClient(cmd.Context())
 		if err != nil {
 			return err
 		}
 
-		if output == "json" {
-			return json.NewEncoder(os.Stdout).Encode(lb)
+		lb, err := client.CreateLoadBalancer(cmd.Context(), &input)
+		if err != nil {
+			return resumableCreateError(err, "load balancer", input.ClientToken)
 		}
 
-		fmt.Printf("Created load balancer: %s (ID: %s)\n", lb.Name, lb.ID)
-		return nil
+		return outputPrinter.printMessage(lb, "Created load balancer: %s (ID: %s)", lb.Name, lb.ID)
 	},
 }
 
 var updateLoadBalancerCmd = &cobra.Command{
 	Use:   "update-load-balancer",
 	Short: "Update an existing load balancer",
+	Long: `Update the load balancer named by --id, or every load balancer matching the selector flags.
+Bulk updates print a summary of the matching load balancers and ask for confirmation, unless --yes is set.
+Tags set with --tag are added to the existing tags of the load balancer, use untag to remove tags.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		// Invalid input is reported before anything is selected or confirmed
+		var fileInput hlb.LoadBalancerUpdate
+		if _, err := readInput(cmd, &fileInput); err != nil {
+			return err
+		}
+
 		client, err := createClient(cmd.Context())
 		if err != nil {
 			return err
 		}
 
-		id, _ := cmd.Flags().GetString("id")
-		var input hlb.LoadBalancerUpdate
-
-		// Check if JSON input file is provided
-		if inputJSON, _ := cmd.Flags().GetString("input-json"); inputJSON != "" {
-			data, err := os.ReadFile(inputJSON)
-			if err != nil {
-				return fmt.Errorf("failed to read input JSON file: %w", err)
+		targets, bulk, err := selectLoadBalancers(cmd, client)
+		if err != nil {
+			return err
+		}
+		if bulk {
+			if cmd.Flags().Changed("name") {
+				return fmt.Errorf("--name cannot be used with selectors, load balancer names must be unique")
 			}
-			if err := json.Unmarshal(data, &input); err != nil {
-				return fmt.Errorf("failed to parse input JSON: %w", err)
+			if len(targets) == 0 {
+				return outputPrinter.printMessage(map[string]interface{}{"items": targets}, "No load balancers match the selector")
 			}
-		} else {
-			// Get values from flags
-			if name, _ := cmd.Flags().GetString("name"); name != "" {
-				input.Name = &name
+			if err := confirmBulk(cmd, "Update", targets); err != nil {
+				return err
 			}
 		}
 
-		lb, err := client.UpdateLoadBalancer(cmd.Context(), id, &input)
-		if err != nil {
-			return err
-		}
+		var updated []*hlb.LoadBalancer
+		for _, target := range targets {
+			input, err := loadBalancerUpdateFromFlags(cmd, &fileInput, func() (*hlb.LoadBalancer, error) {
+				return client.GetLoadBalancer(cmd.Context(), target.ID)
+			})
+			if err != nil {
+				return err
+			}
+
+			if err := validateLoadBalancerUpdate(input); err != nil {
+				return err
+			}
 
-		if output == "json" {
-			return json.NewEncoder(os.Stdout).Encode(lb)
+			lb, err := client.UpdateLoadBalancer(cmd.Context(), target.ID, input)
+			if err != nil {
+				return err
+			}
+			if !bulk {
+				return outputPrinter.printMessage(lb, "Updated load balancer: %s (ID: %s)", lb.Name, lb.ID)
+			}
+			fmt.Fprintf(outputPrinter.events(), "Updated load balancer: %s (ID: %s)\n", lb.Name, lb.ID)
+			updated = append(updated, lb)
 		}
 
-		fmt.Printf("Updated load balancer: %s (ID: %s)\n", lb.Name, lb.ID)
-		return nil
+		return outputPrinter.printMessage(map[string]interface{}{"items": updated}, "Updated %d load balancers", len(updated))
 	},
 }
 
 var deleteLoadBalancerCmd = &cobra.Command{
 	Use:   "delete-load-balancer",
 	Short: "Delete a load balancer",
+	Long: `Delete the load balancer named by --id, or every load balancer matching the selector flags.
+A summary of what will be deleted is shown and confirmation is asked for, unless --yes is set.
+Use --disable-protection to delete load balancers with deletion protection enabled, and --cascade
+to delete their listeners first.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		client, err := createClient(cmd.Context())
 		if err != nil {
 			return err
 		}
 
-		id, _ := cmd.Flags().GetString("id")
-		if err := client.DeleteLoadBalancer(cmd.Context(), id); err != nil {
+		return runDeleteLoadBalancers(cmd, client)
+	},
+}
+
+// applyLoadBalancerCreateFlags sets input from the create-load-balancer flags. When input was read
+// from a file, only the flags set on the command line override its values.
+func applyLoadBalancerCreateFlags(cmd *cobra.Command, input *hlb.LoadBalancerCreate, fromFile bool) error {
+	flags := cmd.Flags()
+	use := func(name string) bool {
+		return !fromFile || flags.Changed(name)
+	}
+
+	// The token is chosen here so that it can be printed if the outcome of the creation is unknown
+	input.ClientToken, _ = flags.GetString("client-token")
+	if input.ClientToken == "" {
+		input.ClientToken = hlb.NewClientToken()
+	}
+
+	if use("name") {
+		input.Name, _ = flags.GetString("name")
+	}
+	if use("name-prefix") {
+		input.NamePrefix, _ = flags.GetString("name-prefix")
+	}
+	if use("internal") {
+		input.Internal, _ = flags.GetBool("internal")
+	}
+	if use("subnets") {
+		input.Subnets, _ = flags.GetStringSlice("subnets")
+	}
+	if use("security-groups") {
+		input.SecurityGroups, _ = flags.GetStringSlice("security-groups")
+	}
+	if use("ip-address-type") {
+		input.IPAddressType, _ = flags.GetString("ip-address-type")
+	}
+	if use("zone-id") {
+		input.ZoneID, _ = flags.GetString("zone-id")
+	}
+	if use("zone-name") {
+		input.ZoneName, _ = flags.GetString("zone-name")
+	}
+	if use("ec2-iam-role") {
+		input.Ec2IamRole, _ = flags.GetString("ec2-iam-role")
+	}
+	if use("idle-timeout") {
+		input.IdleTimeout, _ = flags.GetInt("idle-timeout")
+	}
+	if use("client-keep-alive") {
+		input.ClientKeepAlive, _ = flags.GetInt("client-keep-alive")
+	}
+	if use("connection-draining-timeout") {
+		input.ConnectionDrainingTimeout, _ = flags.GetInt("connection-draining-timeout")
+	}
+	if use("enable-cross-zone-load-balancing") {
+		input.EnableCrossZoneLoadBalancing, _ = flags.GetString("enable-cross-zone-load-balancing")
+	}
+	if use("xff-header-processing-mode") {
+		input.XffHeaderProcessingMode, _ = flags.GetString("xff-header-processing-mode")
+	}
+	if use("enable-http2") {
+		input.EnableHttp2, _ = flags.GetBool("enable-http2")
+	}
+	if use("enable-deletion-protection") {
+		input.EnableDeletionProtection, _ = flags.GetBool("enable-deletion-protection")
+	}
+	if use("preserve-host-header") {
+		input.PreserveHostHeader, _ = flags.GetBool("preserve-host-header")
+	}
+	if use("preferred-maintenance-window") {
+		input.PreferredMaintenanceWindow, _ = flags.GetString("preferred-maintenance-window")
+	}
+
+	if flags.Changed("access-logs-bucket") || flags.Changed("access-logs-prefix") || flags.Changed("access-logs-enabled") {
+		if input.AccessLogs == nil {
+			input.AccessLogs = &hlb.AccessLogs{}
+		}
+		if flags.Changed("access-logs-bucket") {
+			input.AccessLogs.Bucket, _ = flags.GetString("access-logs-bucket")
+		}
+		if flags.Changed("access-logs-prefix") {
+			input.AccessLogs.Prefix, _ = flags.GetString("access-logs-prefix")
+		}
+		if flags.Changed("access-logs-enabled") {
+			input.AccessLogs.Enabled, _ = flags.GetBool("access-logs-enabled")
+		}
+	}
+
+	if spec, _ := flags.GetString("launch-config"); spec != "" {
+		lc, err := parseLaunchConfig(spec, input.LaunchConfig)
+		if err != nil {
+			return err
+		}
+		input.LaunchConfig = lc
+	}
+
+	if tagValues, _ := flags.GetStringArray("tag"); len(tagValues) > 0 {
+		tags, err := parseTags(tagValues)
+		if err != nil {
 			return err
 		}
+		if input.Tags == nil {
+			input.Tags = map[string]string{}
+		}
+		maps.Copy(input.Tags, tags)
+	}
+
+	return nil
+}
+
+// loadBalancerUpdateFromFlags builds an update request from the update-load-balancer flags set on
+// top of base, the input file. Only flags that were explicitly set are sent. Access logs, launch
+// config and tag changes are merged with the settings of the input file, or else with the current
+// settings of the load balancer returned by getCurrent since the API replaces these objects as a
+// whole.
+func loadBalancerUpdateFromFlags(cmd *cobra.Command, base *hlb.LoadBalancerUpdate, getCurrent func() (*hlb.LoadBalancer, error)) (*hlb.LoadBalancerUpdate, error) {
+	flags := cmd.Flags()
+	input := &hlb.LoadBalancerUpdate{}
+	*input = *base
+
+	if flags.Changed("name") {
+		v, _ := flags.GetString("name")
+		input.Name = &v
+	}
+	if flags.Changed("ec2-iam-role") {
+		v, _ := flags.GetString("ec2-iam-role")
+		input.Ec2IamRole = &v
+	}
+	if flags.Changed("security-groups") {
+		input.SecurityGroups, _ = flags.GetStringSlice("security-groups")
+	}
+	if flags.Changed("idle-timeout") {
+		v, _ := flags.GetInt("idle-timeout")
+		input.IdleTimeout = &v
+	}
+	if flags.Changed("client-keep-alive") {
+		v, _ := flags.GetInt("client-keep-alive")
+		input.ClientKeepAlive = &v
+	}
+	if flags.Changed("connection-draining-timeout") {
+		v, _ := flags.GetInt("connection-draining-timeout")
+		input.ConnectionDrainingTimeout = &v
+	}
+	if flags.Changed("enable-cross-zone-load-balancing") {
+		v, _ := flags.GetString("enable-cross-zone-load-balancing")
+		input.EnableCrossZoneLoadBalancing = &v
+	}
+	if flags.Changed("xff-header-processing-mode") {
+		v, _ := flags.GetString("xff-header-processing-mode")
+		input.XffHeaderProcessingMode = &v
+	}
+	if flags.Changed("enable-http2") {
+		v, _ := flags.GetBool("enable-http2")
+		input.EnableHttp2 = &v
+	}
+	if flags.Changed("enable-deletion-protection") {
+		v, _ := flags.GetBool("enable-deletion-protection")
+		input.EnableDeletionProtection = &v
+	}
+	if flags.Changed("preserve-host-header") {
+		v, _ := flags.GetBool("preserve-host-header")
+		input.PreserveHostHeader = &v
+	}
+	if flags.Changed("preferred-maintenance-window") {
+		v, _ := flags.GetString("preferred-maintenance-window")
+		input.PreferredMaintenanceWindow = &v
+	}
+
+	accessLogsChanged := flags.Changed("access-logs-bucket") || flags.Changed("access-logs-prefix") || flags.Changed("access-logs-enabled")
+	launchConfigChanged := flags.Changed("launch-config")
+	tagsChanged := flags.Changed("tag")
+	if (accessLogsChanged && base.AccessLogs == nil) || (launchConfigChanged && base.LaunchConfig == nil) || (tagsChanged && base.Tags == nil) {
+		current, err := getCurrent()
+		if err != nil {
+			return nil, err
+		}
+		if accessLogsChanged && input.AccessLogs == nil {
+			input.AccessLogs = current.AccessLogs
+		}
+		if launchConfigChanged && input.LaunchConfig == nil {
+			input.LaunchConfig = current.LaunchConfig
+		}
+		if tagsChanged && input.Tags == nil {
+			input.Tags = &current.Tags
+		}
+	}
+
+	if tagsChanged {
+		tagValues, _ := flags.GetStringArray("tag")
+		tags, err := parseTags(tagValues)
+		if err != nil {
+			return nil, err
+		}
+		// Tags of the input file, or else the current tags, are kept unless a flag overrides them
+		merged := map[string]string{}
+		maps.Copy(merged, *input.Tags)
+		maps.Copy(merged, tags)
+		input.Tags = &merged
+	}
+
+	if accessLogsChanged {
+		accessLogs := &hlb.AccessLogs{}
+		if input.AccessLogs != nil {
+			*accessLogs = *input.AccessLogs
+		}
+		if flags.Changed("access-logs-bucket") {
+			accessLogs.Bucket, _ = flags.GetString("access-logs-bucket")
+		}
+		if flags.Changed("access-logs-prefix") {
+			accessLogs.Prefix, _ = flags.GetString("access-logs-prefix")
+		}
+		if flags.Changed("access-logs-enabled") {
+			accessLogs.Enabled, _ = flags.GetBool("access-logs-enabled")
+		}
+		input.AccessLogs = accessLogs
+	}
+
+	if launchConfigChanged {
+		spec, _ := flags.GetString("launch-config")
+		lc, err := parseLaunchConfig(spec, input.LaunchConfig)
+		if err != nil {
+			return nil, err
+		}
+		input.LaunchConfig = lc
+	}
 
-		if output == "json" {
-			fmt.Println("{\"status\": \"deleted\"}")
+	return input, nil
+}
+
+// loadBalancerTable renders load balancers in the text and wide formats
+func loadBalancerTable(loadBalancers []hlb.LoadBalancer) *table {
+	t := &table{
+		headers:     []string{"ID", "NAME", "DNS NAME", "STATE"},
+		wideHeaders: []string{"INTERNAL", "IP ADDRESS TYPE", "INSTANCE TYPE", "MIN", "MAX", "SUBNETS", "VERSION", "CREATED"},
+	}
+	for _, lb := range loadBalancers {
+		t.rows = append(t.rows, []string{lb.ID, lb.Name, lb.DNSName, lb.State})
+
+		instanceType, minCount, maxCount := "<default>", "-", "-"
+		if lb.LaunchConfig != nil {
+			if lb.LaunchConfig.InstanceType != "" {
+				instanceType = lb.LaunchConfig.InstanceType
+			}
+			minCount = strconv.Itoa(lb.LaunchConfig.MinInstanceCount)
+			maxCount = strconv.Itoa(lb.LaunchConfig.MaxInstanceCount)
+		}
+		version := ""
+		if lb.DeploymentStatus != nil {
+			version = lb.DeploymentStatus.Version
+		}
+		t.wideRows = append(t.wideRows, []string{
+			strconv.FormatBool(lb.Internal),
+			lb.IPAddressType,
+			instanceType,
+			minCount,
+			maxCount,
+			orNone(strings.Join(lb.Subnets, ",")),
+			orNone(version),
+			lb.CreatedAt.Format(time.RFC3339),
+		})
+	}
+	return t
+}
+
+// describeLoadBalancer renders the detailed text view of a load balancer
+func describeLoadBalancer(lb *hlb.LoadBalancer) describer {
+	return func(w io.Writer) {
+		d := newDescribeWriter(w)
+		defer d.flush()
+
+		d.field("ID", lb.ID)
+		d.field("Name", lb.Name)
+		d.field("DNS Name", lb.DNSName)
+		d.field("State", lb.State)
+		d.field("Account", lb.AccountID)
+		d.field("Zone", fmt.Sprintf("%s (%s)", lb.ZoneName, lb.ZoneID))
+		d.field("Internal", lb.Internal)
+		d.field("IP Address Type", lb.IPAddressType)
+		d.field("Subnets", orNone(strings.Join(lb.Subnets, ", ")))
+		d.field("Security Groups", orNone(strings.Join(lb.SecurityGroups, ", ")))
+		d.field("EC2 IAM Role", lb.Ec2IamRole)
+		d.field("Idle Timeout", fmt.Sprintf("%ds", lb.IdleTimeout))
+		d.field("Client Keep Alive", fmt.Sprintf("%ds", lb.ClientKeepAlive))
+		d.field("Connection Draining Timeout", fmt.Sprintf("%dm", lb.ConnectionDrainingTimeout))
+		d.field("Cross-Zone Load Balancing", lb.EnableCrossZoneLoadBalancing)
+		d.field("XFF Header Processing Mode", lb.XffHeaderProcessingMode)
+		d.field("HTTP/2", lb.EnableHttp2)
+		d.field("Preserve Host Header", lb.PreserveHostHeader)
+		d.field("Deletion Protection", lb.EnableDeletionProtection)
+		d.field("Maintenance Window", orNone(lb.PreferredMaintenanceWindow))
+
+		if lb.LaunchConfig != nil {
+			d.section("Launch Config")
+			d.nested(func() {
+				d.field("Instance Type", orNone(lb.LaunchConfig.InstanceType))
+				d.field("Min Instances", lb.LaunchConfig.MinInstanceCount)
+				d.field("Max Instances", lb.LaunchConfig.MaxInstanceCount)
+				d.field("Target CPU Usage", fmt.Sprintf("%d%%", lb.LaunchConfig.TargetCPUUsage))
+			})
 		} else {
-			fmt.Printf("Deleted load balancer: %s\n", id)
+			d.field("Launch Config", "<default>")
 		}
 
-		return nil
-	},
+		if lb.AccessLogs != nil {
+			d.section("Access Logs")
+			d.nested(func() {
+				d.field("Enabled", lb.AccessLogs.Enabled)
+				d.field("Bucket", orNone(lb.AccessLogs.Bucket))
+				d.field("Prefix", orNone(lb.AccessLogs.Prefix))
+			})
+		} else {
+			d.field("Access Logs", "<none>")
+		}
+
+		if len(lb.Tags) > 0 {
+			keys := make([]string, 0, len(lb.Tags))
+			for k := range lb.Tags {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			d.section("Tags")
+			d.nested(func() {
+				for _, k := range keys {
+					d.field(k, lb.Tags[k])
+				}
+			})
+		} else {
+			d.field("Tags", "<none>")
+		}
+
+		if lb.DeploymentStatus != nil {
+			d.section("Deployment Status")
+			d.nested(func() {
+				d.field("Version", orNone(lb.DeploymentStatus.Version))
+				if lb.DeploymentStatus.ErrorMessage != "" {
+					d.field("Error", lb.DeploymentStatus.ErrorMessage)
+				}
+			})
+		}
+
+		d.field("Created At", lb.CreatedAt.Format(time.RFC3339))
+		d.field("Updated At", lb.UpdatedAt.Format(time.RFC3339))
+	}
+}
+
+// listAllLoadBalancers pages through ListLoadBalancers and returns every load balancer
+func listAllLoadBalancers(ctx context.Context, client *hlb.Client) ([]hlb.LoadBalancer, error) {
+	var all []hlb.LoadBalancer
+	nextToken := ""
+	for {
+		page, token, err := client.ListLoadBalancers(ctx, 100, nextToken)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if token == "" {
+			return all, nil
+		}
+		nextToken = token
+	}
+}
+
+// isLiveLoadBalancerState reports whether a load balancer in state exists and is not being deleted
+func isLiveLoadBalancerState(state string) bool {
+	switch state {
+	case hlb.LBStateDeleted, hlb.LBStateDeleting, hlb.LBStatePendingDeletion:
+		return false
+	}
+	return true
+}
+
+// loadBalancerCreateFromLive returns the create request reproducing the settings of lb
+func loadBalancerCreateFromLive(lb *hlb.LoadBalancer) *hlb.LoadBalancerCreate {
+	return &hlb.LoadBalancerCreate{
+		AccessLogs:                   lb.AccessLogs,
+		ClientKeepAlive:              lb.ClientKeepAlive,
+		ConnectionDrainingTimeout:    lb.ConnectionDrainingTimeout,
+		Ec2IamRole:                   lb.Ec2IamRole,
+		EnableCrossZoneLoadBalancing: lb.EnableCrossZoneLoadBalancing,
+		EnableDeletionProtection:     lb.EnableDeletionProtection,
+		EnableHttp2:                  lb.EnableHttp2,
+		IdleTimeout:                  lb.IdleTimeout,
+		Internal:                     lb.Internal,
+		IPAddressType:                lb.IPAddressType,
+		LaunchConfig:                 lb.LaunchConfig,
+		Name:                         lb.Name,
+		PreferredMaintenanceWindow:   lb.PreferredMaintenanceWindow,
+		PreserveHostHeader:           lb.PreserveHostHeader,
+		SecurityGroups:               lb.SecurityGroups,
+		Subnets:                      lb.Subnets,
+		Tags:                         lb.Tags,
+		XffHeaderProcessingMode:      lb.XffHeaderProcessingMode,
+		ZoneID:                       lb.ZoneID,
+		ZoneName:                     lb.ZoneName,
+	}
 }
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"gitlab.guerraz.net/HLB/hlb-terraform-provider/hlb"
+)
+
+func TestLoadBalancerUpdateFromFlags(t *testing.T) {
+	current := &hlb.LoadBalancer{
+		ID:         "lb-1",
+		Tags:       map[string]string{"team": "edge", "env": "dev"},
+		AccessLogs: &hlb.AccessLogs{Bucket: "logs", Prefix: "web", Enabled: true},
+	}
+
+	tests := []struct {
+		name        string
+		args        []string
+		base        hlb.LoadBalancerUpdate
+		wantTags    *map[string]string
+		wantLogs    *hlb.AccessLogs
+		wantCurrent bool // whether the current load balancer is read
+	}{
+		{
+			name:        "tags are added to the current tags",
+			args:        []string{"--tag", "env=prod", "--tag", "owner=ops"},
+			wantTags:    &map[string]string{"team": "edge", "env": "prod", "owner": "ops"},
+			wantCurrent: true,
+		},
+		{
+			name:     "tags are added to the tags of the input file",
+			args:     []string{"--tag", "env=prod"},
+			base:     hlb.LoadBalancerUpdate{Tags: &map[string]string{"team": "core"}},
+			wantTags: &map[string]string{"team": "core", "env": "prod"},
+		},
+		{
+			name:        "access logs are merged with the current settings",
+			args:        []string{"--access-logs-prefix", "api"},
+			wantLogs:    &hlb.AccessLogs{Bucket: "logs", Prefix: "api", Enabled: true},
+			wantCurrent: true,
+		},
+		{
+			name: "tags are not sent unless set",
+			args: []string{"--idle-timeout", "120"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			addLoadBalancerUpdateFlags(cmd)
+			if err := cmd.ParseFlags(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			read := false
+			input, err := loadBalancerUpdateFromFlags(cmd, &tt.base, func() (*hlb.LoadBalancer, error) {
+				read = true
+				return current, nil
+			})
+			if err != nil {
+				t.Fatalf("loadBalancerUpdateFromFlags() error = %v", err)
+			}
+			if !reflect.DeepEqual(input.Tags, tt.wantTags) {
+				t.Errorf("Tags = %v, want %v", input.Tags, tt.wantTags)
+			}
+			if !reflect.DeepEqual(input.AccessLogs, tt.wantLogs) {
+				t.Errorf("AccessLogs = %+v, want %+v", input.AccessLogs, tt.wantLogs)
+			}
+			if read != tt.wantCurrent {
+				t.Errorf("read the current load balancer: %t, want %t", read, tt.wantCurrent)
+			}
+		})
+	}
+
+	if want := map[string]string{"team": "edge", "env": "dev"}; !reflect.DeepEqual(current.Tags, want) {
+		t.Errorf("current tags were modified to %v", current.Tags)
+	}
+}
@@ -2,12 +2,19 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/hashicorp/go-hclog"
 	"github.com/spf13/cobra"
 	"gitlab.guerraz.net/HLB/hlb-terraform-provider/hlb"
+	"gitlab.guerraz.net/HLB/hlb-terraform-provider/internal/telemetry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
 )
 
 var (
@@ -17,6 +24,10 @@ var (
 	output    string
 	debug     bool
 	partition string
+	dryRun    bool
+
+	// outputPrinter renders command results in the format selected with --output
+	outputPrinter *printer
 )
 
 func init() {
@@ -25,15 +36,43 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&profile, "profile", "", "AWS profile to use")
 	rootCmd.PersistentFlags().StringVar(&region, "region", "", "AWS region to use")
 	rootCmd.PersistentFlags().StringVar(&apiKey, "api-key", "", "HLB API key")
-	rootCmd.PersistentFlags().StringVar(&output, "output", "text", "Output format (json/text)")
-	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "Enable debug output")
+	rootCmd.PersistentFlags().StringVarP(&output, "output", "o", "text", "Output format (text/wide/json/yaml/jsonpath=.../go-template=...)")
+	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "Log API requests to stderr, same as HLB_LOG=debug")
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "Print the requests that would change resources instead of sending them")
 }
 
 var rootCmd = &cobra.Command{
 	Use:   "zonehero",
 	Short: "ZoneHero CLI - Manage HLB resources",
 	Long: `ZoneHero CLI provides a command-line interface to manage HLB (Hero Load Balancer) resources.
-It supports managing load balancers and listeners with various operations like create, list, update, and delete.`,
+It supports managing load balancers and listeners with various operations like create, list, update, and delete.
+
+API requests are logged to stderr with --debug, or at the level set with the HLB_LOG or TF_LOG
+environment variables (trace also logs request and response bodies, with credentials redacted).
+Set HLB_RECORD to a file to record the API requests and responses to it, and HLB_REPLAY to serve
+the responses recorded in a file instead of calling the API.
+
+Traces and metrics of the API requests and waits are exported with the exporter selected with
+OTEL_TRACES_EXPORTER and OTEL_METRICS_EXPORTER (otlp, console or none) and configured with the
+standard OTEL_EXPORTER_OTLP_* variables. The console exporter writes to stderr, or to the file
+named by HLB_TELEMETRY_FILE.
+
+Errors are written to stderr, as a JSON or YAML object with the code, message, HTTP status and
+request ID of the error when --output is json or yaml.
+
+` + exitCodesHelp,
+	// Errors are reported by main, in the format selected with --output
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := applyContext(cmd); err != nil {
+			return err
+		}
+
+		var err error
+		outputPrinter, err = newPrinter()
+		return err
+	},
 }
 
 var hlbCmd = &cobra.Command{
@@ -42,14 +81,23 @@ var hlbCmd = &cobra.Command{
 	Long:  `Manage HLB (Hero Load Balancer) resources including load balancers and listeners.`,
 }
 
-func createClient(ctx context.Context) (*hlb.Client, error) {
+func resolveAPIKey() (string, error) {
 	if apiKey == "" {
 		apiKey = os.Getenv("HLB_API_KEY")
 	}
+	if apiKey == "" && activeContext != nil {
+		var err error
+		if apiKey, err = activeContext.resolveAPIKey(); err != nil {
+			return "", err
+		}
+	}
 	if apiKey == "" {
-		return nil, fmt.Errorf("HLB API key is required. Set it using --api-key flag or HLB_API_KEY environment variable")
+		return "", errMissingAPIKey
 	}
+	return apiKey, nil
+}
 
+func loadAWSConfig(ctx context.Context) (aws.Config, error) {
 	opts := []func(*config.LoadOptions) error{}
 	if profile != "" {
 		opts = append(opts, config.WithSharedConfigProfile(profile))
@@ -60,23 +108,97 @@ func createClient(ctx context.Context) (*hlb.Client, error) {
 
 	awsCfg, err := config.LoadDefaultConfig(ctx, opts...)
 	if err != nil {
-		return nil, fmt.Errorf("error loading AWS config: %v", err)
+		return aws.Config{}, fmt.Errorf("error loading AWS config: %v", err)
+	}
+	return awsCfg, nil
+}
+
+func createClient(ctx context.Context) (*hlb.Client, error) {
+	apiKey, err := resolveAPIKey()
+	if err != nil {
+		return nil, err
 	}
 
-	client, err := hlb.NewClient(ctx, apiKey, awsCfg, partition)
+	awsCfg, err := loadAWSConfig(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("error creating HLB client: %v", err)
+		return nil, err
 	}
 
-	client.SetDebug(debug)
+	opts := []hlb.ClientOption{hlb.WithCassetteFromEnv(), hlb.WithUserAgent(userAgent())}
+	if activeContext != nil && activeContext.Endpoint != "" {
+		opts = append(opts, hlb.WithEndpoint(activeContext.Endpoint))
+	}
+	if dryRun {
+		opts = append(opts, hlb.WithDryRun(os.Stderr))
+	}
+	if logger := newLogger(); logger != nil {
+		opts = append(opts, hlb.WithLogger(logger))
+	}
+
+	client, err := hlb.NewClient(ctx, apiKey, awsCfg, partition, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating HLB client: %w", err)
+	}
 	return client, nil
 }
 
+// newLogger returns the logger of the API requests, writing to stderr so that it never mixes with
+// the output of the command. It returns nil when logging is disabled.
+func newLogger() hlb.Logger {
+	level := os.Getenv("HLB_LOG")
+	if level == "" {
+		level = os.Getenv("TF_LOG")
+	}
+	if debug {
+		level = "debug"
+	}
+
+	// TF_LOG=json logs everything in JSON
+	jsonFormat := strings.EqualFold(level, "json")
+	if jsonFormat {
+		level = "trace"
+	}
+	logLevel := hclog.LevelFromString(level)
+	if logLevel == hclog.NoLevel || logLevel == hclog.Off {
+		return nil
+	}
+
+	return hlb.NewHCLogger(hclog.New(&hclog.LoggerOptions{
+		Name:       "hlb",
+		Level:      logLevel,
+		Output:     os.Stderr,
+		JSONFormat: jsonFormat,
+	}))
+}
+
 func main() {
 	rootCmd.AddCommand(hlbCmd)
+	trackCommandStart(rootCmd)
+	registerIDCompletions(rootCmd)
+
+	// Telemetry must never prevent commands from running
+	ctx := context.Background()
+	shutdownTelemetry, err := telemetry.Setup(ctx, "zonehero")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: telemetry disabled: %v\n", err)
+	}
+
+	// The span of the command is the parent of the spans of its API requests
+	ctx, span := otel.Tracer("zonehero").Start(ctx, rootCmd.Name())
+	cmd, err := rootCmd.ExecuteContextC(ctx)
+	span.SetName(cmd.CommandPath())
+	exitCode := 0
+	if err != nil && !errors.Is(err, errSkeletonPrinted) {
+		if !commandStarted {
+			err = &usageError{err: err}
+		}
+		exitCode = reportError(os.Stderr, err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+	shutdownTelemetry(ctx)
 
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	if exitCode != 0 {
+		os.Exit(exitCode)
 	}
 }
@@ -0,0 +1,575 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"gitlab.guerraz.net/HLB/hlb-terraform-provider/hlb"
+	"gopkg.in/yaml.v3"
+)
+
+// manifest is the declarative description of load balancers and their listeners read by the
+// apply and diff commands. Load balancers are identified by name and listeners by port.
+type manifest struct {
+	LoadBalancers []manifestLoadBalancer `json:"loadBalancers"`
+}
+
+// manifestLoadBalancer uses the API field names. Omitted settings take the same defaults as the
+// Terraform provider, except accessLogs, launchConfig, securityGroups and tags which are left
+// unmanaged when omitted.
+type manifestLoadBalancer struct {
+	Name                         string             `json:"name"`
+	ZoneID                       string             `json:"zoneId"`
+	ZoneName                     string             `json:"zoneName"`
+	Subnets                      []string           `json:"subnets"`
+	SecurityGroups               []string           `json:"securityGroups,omitempty"`
+	Internal                     bool               `json:"internal"`
+	IPAddressType                string             `json:"ipAddressType"`
+	Ec2IamRole                   string             `json:"ec2IamRole"`
+	EnableCrossZoneLoadBalancing string             `json:"enableCrossZoneLoadBalancing"`
+	EnableDeletionProtection     bool               `json:"enableDeletionProtection"`
+	EnableHttp2                  bool               `json:"enableHttp2"`
+	IdleTimeout                  int                `json:"idleTimeout"`
+	ClientKeepAlive              int                `json:"clientKeepAlive"`
+	ConnectionDrainingTimeout    int                `json:"connectionDrainingTimeout"`
+	PreferredMaintenanceWindow   string             `json:"preferredMaintenanceWindow"`
+	PreserveHostHeader           bool               `json:"preserveHostHeader"`
+	XffHeaderProcessingMode      string             `json:"xffHeaderProcessingMode"`
+	AccessLogs                   *hlb.AccessLogs    `json:"accessLogs,omitempty"`
+	LaunchConfig                 *hlb.LaunchConfig  `json:"launchConfig,omitempty"`
+	Tags                         map[string]string  `json:"tags,omitempty"`
+	Listeners                    []manifestListener `json:"listeners"`
+}
+
+type manifestListener struct {
+	Port                     int     `json:"port"`
+	Protocol                 string  `json:"protocol"`
+	TargetGroupARN           string  `json:"targetGroupArn"`
+	CertificateSecretsName   string  `json:"certificateSecretsName,omitempty"`
+	ALPNPolicy               string  `json:"alpnPolicy,omitempty"`
+	EnableDeletionProtection bool    `json:"enableDeletionProtection"`
+	OverprovisioningFactor   float64 `json:"overprovisioningFactor"`
+}
+
+// UnmarshalJSON applies the provider schema defaults before decoding
+func (m *manifestLoadBalancer) UnmarshalJSON(data []byte) error {
+	type plain manifestLoadBalancer
+	lb := plain{
+		IPAddressType:                hlb.LBIpAddressTypeV4Only,
+		Ec2IamRole:                   hlb.LBEc2IamRoleStandard,
+		EnableCrossZoneLoadBalancing: hlb.LBCrossAZPolicyAvoid,
+		EnableHttp2:                  true,
+		IdleTimeout:                  60,
+		ClientKeepAlive:              3600,
+		ConnectionDrainingTimeout:    10,
+		XffHeaderProcessingMode:      "append",
+	}
+	if err := decodeStrict(data, &lb); err != nil {
+		return err
+	}
+	*m = manifestLoadBalancer(lb)
+	return nil
+}
+
+// UnmarshalJSON applies the provider schema defaults before decoding
+func (m *manifestListener) UnmarshalJSON(data []byte) error {
+	type plain manifestListener
+	l := plain{OverprovisioningFactor: 1.1}
+	if err := decodeStrict(data, &l); err != nil {
+		return err
+	}
+	*m = manifestListener(l)
+	return nil
+}
+
+func decodeStrict(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// loadManifest reads a manifest in JSON or YAML, depending on the file extension
+func loadManifest(path string) (*manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	// YAML documents are converted to JSON so that both formats share the JSON field names
+	if ext := strings.ToLower(filepath.Ext(path)); ext != ".json" {
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+		}
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+		}
+	}
+
+	var m manifest
+	if err := decodeStrict(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+func (m *manifest) validate() error {
+	names := map[string]bool{}
+	for i := range m.LoadBalancers {
+		lb := &m.LoadBalancers[i]
+		if names[lb.Name] {
+			return fmt.Errorf("load balancer %q is declared more than once", lb.Name)
+		}
+		names[lb.Name] = true
+
+		if err := validateLoadBalancerCreate(lb.createInput()); err != nil {
+			return fmt.Errorf("load balancer %q: %w", lb.Name, err)
+		}
+
+		ports := map[int]bool{}
+		for j := range lb.Listeners {
+			l := &lb.Listeners[j]
+			if ports[l.Port] {
+				return fmt.Errorf("load balancer %q: listener on port %d is declared more than once", lb.Name, l.Port)
+			}
+			ports[l.Port] = true
+
+			if err := validateListenerCreate(l.createInput()); err != nil {
+				return fmt.Errorf("load balancer %q listener %d: %w", lb.Name, l.Port, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (m *manifestLoadBalancer) createInput() *hlb.LoadBalancerCreate {
+	return &hlb.LoadBalancerCreate{
+		AccessLogs:                   m.AccessLogs,
+		ClientKeepAlive:              m.ClientKeepAlive,
+		ConnectionDrainingTimeout:    m.ConnectionDrainingTimeout,
+		Ec2IamRole:                   m.Ec2IamRole,
+		EnableCrossZoneLoadBalancing: m.EnableCrossZoneLoadBalancing,
+		EnableDeletionProtection:     m.EnableDeletionProtection,
+		EnableHttp2:                  m.EnableHttp2,
+		IdleTimeout:                  m.IdleTimeout,
+		Internal:                     m.Internal,
+		IPAddressType:                m.IPAddressType,
+		LaunchConfig:                 m.LaunchConfig,
+		Name:                         m.Name,
+		PreferredMaintenanceWindow:   m.PreferredMaintenanceWindow,
+		PreserveHostHeader:           m.PreserveHostHeader,
+		SecurityGroups:               m.SecurityGroups,
+		Subnets:                      m.Subnets,
+		Tags:                         m.Tags,
+		XffHeaderProcessingMode:      m.XffHeaderProcessingMode,
+		ZoneID:                       m.ZoneID,
+		ZoneName:                     m.ZoneName,
+	}
+}
+
+func (l *manifestListener) createInput() *hlb.ListenerCreate {
+	return &hlb.ListenerCreate{
+		ALPNPolicy:               l.ALPNPolicy,
+		CertificateSecretsName:   l.CertificateSecretsName,
+		EnableDeletionProtection: l.EnableDeletionProtection,
+		OverprovisioningFactor:   l.OverprovisioningFactor,
+		Port:                     l.Port,
+		Protocol:                 l.Protocol,
+		TargetGroupARN:           l.TargetGroupARN,
+	}
+}
+
+const (
+	planCreate = "create"
+	planUpdate = "update"
+	planDelete = "delete"
+	planNoop   = "no-op"
+)
+
+// applyPlan lists the actions needed to make live state match a manifest
+type applyPlan struct {
+	LoadBalancers []*loadBalancerPlan `json:"loadBalancers"`
+}
+
+type loadBalancerPlan struct {
+	Action    string          `json:"action"`
+	Name      string          `json:"name"`
+	ID        string          `json:"id,omitempty"`
+	Changes   []planChange    `json:"changes,omitempty"`
+	Listeners []*listenerPlan `json:"listeners,omitempty"`
+
+	desired *manifestLoadBalancer
+	update  *hlb.LoadBalancerUpdate
+	// current and currentListeners are the live state deleted by a planDelete action
+	current          *hlb.LoadBalancer
+	currentListeners []hlb.Listener
+}
+
+type listenerPlan struct {
+	Action   string       `json:"action"`
+	Port     int          `json:"port"`
+	Protocol string       `json:"protocol"`
+	ID       string       `json:"id,omitempty"`
+	Changes  []planChange `json:"changes,omitempty"`
+
+	desired *manifestListener
+	update  *hlb.ListenerUpdate
+	current *hlb.Listener
+}
+
+type planChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// planCounts holds the number of create, update and delete actions of a kind of resource
+type planCounts struct {
+	Create, Update, Delete int
+}
+
+func (c *planCounts) add(action string) {
+	switch action {
+	case planCreate:
+		c.Create++
+	case planUpdate:
+		c.Update++
+	case planDelete:
+		c.Delete++
+	}
+}
+
+// counts returns the number of actions planned for load balancers and listeners
+func (p *applyPlan) counts() (loadBalancers, listeners planCounts) {
+	for _, lb := range p.LoadBalancers {
+		loadBalancers.add(lb.Action)
+		for _, l := range lb.Listeners {
+			listeners.add(l.Action)
+		}
+	}
+	return loadBalancers, listeners
+}
+
+func (p *applyPlan) hasChanges() bool {
+	lbs, listeners := p.counts()
+	return lbs != planCounts{} || listeners != planCounts{}
+}
+
+// liveReader reads the live state compared with a manifest
+type liveReader struct {
+	listListeners   func(loadBalancerID string) ([]hlb.Listener, error)
+	getLoadBalancer func(id string) (*hlb.LoadBalancer, error)
+	getListener     func(loadBalancerID, listenerID string) (*hlb.Listener, error)
+}
+
+// buildPlan compares m with the live load balancers and their listeners. Resources missing from
+// the manifest are only deleted when prune is set, along with the listeners of the deleted load
+// balancers. Resources to update are read again before being compared, listed resources have no
+// ETag to make their update conditional on.
+func buildPlan(m *manifest, live []hlb.LoadBalancer, reader liveReader, prune bool) (*applyPlan, error) {
+	byName := map[string]*hlb.LoadBalancer{}
+	for i := range live {
+		lb := &live[i]
+		if !isLiveLoadBalancerState(lb.State) {
+			continue
+		}
+		if _, ok := byName[lb.Name]; ok {
+			return nil, fmt.Errorf("several load balancers are named %q, rename them before using apply", lb.Name)
+		}
+		byName[lb.Name] = lb
+	}
+
+	plan := &applyPlan{}
+	declared := map[string]bool{}
+	for i := range m.LoadBalancers {
+		desired := &m.LoadBalancers[i]
+		declared[desired.Name] = true
+
+		current, exists := byName[desired.Name]
+		if !exists {
+			lbPlan := &loadBalancerPlan{Action: planCreate, Name: desired.Name, desired: desired}
+			for j := range desired.Listeners {
+				l := &desired.Listeners[j]
+				lbPlan.Listeners = append(lbPlan.Listeners, &listenerPlan{Action: planCreate, Port: l.Port, Protocol: l.Protocol, desired: l})
+			}
+			plan.LoadBalancers = append(plan.LoadBalancers, lbPlan)
+			continue
+		}
+
+		changes, update, err := diffLoadBalancer(desired, current)
+		if err != nil {
+			return nil, err
+		}
+		if len(changes) > 0 {
+			if current, err = reader.getLoadBalancer(current.ID); err != nil {
+				return nil, err
+			}
+			if changes, update, err = diffLoadBalancer(desired, current); err != nil {
+				return nil, err
+			}
+		}
+		lbPlan := &loadBalancerPlan{Action: planNoop, Name: desired.Name, ID: current.ID, Changes: changes, desired: desired}
+		if len(changes) > 0 {
+			lbPlan.Action = planUpdate
+			lbPlan.update = update
+		}
+
+		listeners, err := reader.listListeners(current.ID)
+		if err != nil {
+			return nil, err
+		}
+		loadBalancerID := current.ID
+		lbPlan.Listeners, err = planListeners(desired.Listeners, listeners, prune, func(listenerID string) (*hlb.Listener, error) {
+			return reader.getListener(loadBalancerID, listenerID)
+		})
+		if err != nil {
+			return nil, err
+		}
+		plan.LoadBalancers = append(plan.LoadBalancers, lbPlan)
+	}
+
+	if prune {
+		var extra []*loadBalancerPlan
+		for name, lb := range byName {
+			if declared[name] {
+				continue
+			}
+			listeners, err := reader.listListeners(lb.ID)
+			if err != nil {
+				return nil, err
+			}
+			// Nothing is updated, no listener is read again
+			listenerPlans, err := planListeners(nil, listeners, true, nil)
+			if err != nil {
+				return nil, err
+			}
+			extra = append(extra, &loadBalancerPlan{
+				Action:           planDelete,
+				Name:             name,
+				ID:               lb.ID,
+				Listeners:        listenerPlans,
+				current:          lb,
+				currentListeners: listeners,
+			})
+		}
+		sort.Slice(extra, func(i, j int) bool { return extra[i].Name < extra[j].Name })
+		plan.LoadBalancers = append(plan.LoadBalancers, extra...)
+	}
+
+	return plan, nil
+}
+
+// planListeners compares the desired listeners of a load balancer with live ones. getListener reads
+// again the listeners to update.
+func planListeners(desired []manifestListener, live []hlb.Listener, prune bool, getListener func(listenerID string) (*hlb.Listener, error)) ([]*listenerPlan, error) {
+	byPort := map[int]*hlb.Listener{}
+	for i := range live {
+		byPort[live[i].Port] = &live[i]
+	}
+
+	var plans []*listenerPlan
+	declared := map[int]bool{}
+	for i := range desired {
+		l := &desired[i]
+		declared[l.Port] = true
+
+		current, exists := byPort[l.Port]
+		if !exists {
+			plans = append(plans, &listenerPlan{Action: planCreate, Port: l.Port, Protocol: l.Protocol, desired: l})
+			continue
+		}
+
+		changes, update, err := diffListener(l, current)
+		if err != nil {
+			return nil, err
+		}
+		if len(changes) > 0 {
+			if current, err = getListener(current.ID); err != nil {
+				return nil, err
+			}
+			if changes, update, err = diffListener(l, current); err != nil {
+				return nil, err
+			}
+		}
+		lPlan := &listenerPlan{Action: planNoop, Port: l.Port, Protocol: l.Protocol, ID: current.ID, Changes: changes, desired: l, current: current}
+		if len(changes) > 0 {
+			lPlan.Action = planUpdate
+			lPlan.update = update
+		}
+		plans = append(plans, lPlan)
+	}
+
+	if prune {
+		for i := range live {
+			if l := &live[i]; !declared[l.Port] {
+				plans = append(plans, &listenerPlan{Action: planDelete, Port: l.Port, Protocol: l.Protocol, ID: l.ID, current: l})
+			}
+		}
+	}
+
+	sort.SliceStable(plans, func(i, j int) bool { return plans[i].Port < plans[j].Port })
+	return plans, nil
+}
+
+// diffLoadBalancer returns the changes between desired and live, and the update that applies
+// them to the version of live. Settings that cannot be updated in place are reported as errors.
+func diffLoadBalancer(desired *manifestLoadBalancer, live *hlb.LoadBalancer) ([]planChange, *hlb.LoadBalancerUpdate, error) {
+	var immutable []string
+	if !sameStringSet(desired.Subnets, live.Subnets) {
+		immutable = append(immutable, "subnets")
+	}
+	if desired.Internal != live.Internal {
+		immutable = append(immutable, "internal")
+	}
+	if desired.IPAddressType != live.IPAddressType {
+		immutable = append(immutable, "ipAddressType")
+	}
+	if desired.ZoneID != live.ZoneID {
+		immutable = append(immutable, "zoneId")
+	}
+	if desired.ZoneName != live.ZoneName {
+		immutable = append(immutable, "zoneName")
+	}
+	if len(immutable) > 0 {
+		return nil, nil, fmt.Errorf("load balancer %q (%s): %s cannot be changed in place, delete and recreate it", desired.Name, live.ID, strings.Join(immutable, ", "))
+	}
+
+	var changes []planChange
+	update := &hlb.LoadBalancerUpdate{IfMatch: live.ETag()}
+	changed := func(field string, from, to interface{}) bool {
+		if reflect.DeepEqual(from, to) {
+			return false
+		}
+		changes = append(changes, planChange{Field: field, From: from, To: to})
+		return true
+	}
+
+	if changed("ec2IamRole", live.Ec2IamRole, desired.Ec2IamRole) {
+		update.Ec2IamRole = &desired.Ec2IamRole
+	}
+	if changed("enableCrossZoneLoadBalancing", live.EnableCrossZoneLoadBalancing, desired.EnableCrossZoneLoadBalancing) {
+		update.EnableCrossZoneLoadBalancing = &desired.EnableCrossZoneLoadBalancing
+	}
+	if changed("enableDeletionProtection", live.EnableDeletionProtection, desired.EnableDeletionProtection) {
+		update.EnableDeletionProtection = &desired.EnableDeletionProtection
+	}
+	if changed("enableHttp2", live.EnableHttp2, desired.EnableHttp2) {
+		update.EnableHttp2 = &desired.EnableHttp2
+	}
+	if changed("idleTimeout", live.IdleTimeout, desired.IdleTimeout) {
+		update.IdleTimeout = &desired.IdleTimeout
+	}
+	if changed("clientKeepAlive", live.ClientKeepAlive, desired.ClientKeepAlive) {
+		update.ClientKeepAlive = &desired.ClientKeepAlive
+	}
+	if changed("connectionDrainingTimeout", live.ConnectionDrainingTimeout, desired.ConnectionDrainingTimeout) {
+		update.ConnectionDrainingTimeout = &desired.ConnectionDrainingTimeout
+	}
+	if changed("preferredMaintenanceWindow", live.PreferredMaintenanceWindow, desired.PreferredMaintenanceWindow) {
+		update.PreferredMaintenanceWindow = &desired.PreferredMaintenanceWindow
+	}
+	if changed("preserveHostHeader", live.PreserveHostHeader, desired.PreserveHostHeader) {
+		update.PreserveHostHeader = &desired.PreserveHostHeader
+	}
+	if changed("xffHeaderProcessingMode", live.XffHeaderProcessingMode, desired.XffHeaderProcessingMode) {
+		update.XffHeaderProcessingMode = &desired.XffHeaderProcessingMode
+	}
+
+	// Settings omitted from the manifest are left unmanaged
+	if desired.SecurityGroups != nil && !sameStringSet(desired.SecurityGroups, live.SecurityGroups) {
+		changes = append(changes, planChange{Field: "securityGroups", From: live.SecurityGroups, To: desired.SecurityGroups})
+		update.SecurityGroups = desired.SecurityGroups
+	}
+	if desired.AccessLogs != nil && changed("accessLogs", live.AccessLogs, desired.AccessLogs) {
+		update.AccessLogs = desired.AccessLogs
+	}
+	if desired.LaunchConfig != nil {
+		// Zero launch config values are chosen by the API, so only compare the ones that are set
+		merged := mergeLaunchConfig(live.LaunchConfig, desired.LaunchConfig)
+		if changed("launchConfig", live.LaunchConfig, merged) {
+			update.LaunchConfig = merged
+		}
+	}
+	if desired.Tags != nil && !(len(desired.Tags) == 0 && len(live.Tags) == 0) && changed("tags", live.Tags, desired.Tags) {
+		update.Tags = &desired.Tags
+	}
+
+	return changes, update, nil
+}
+
+// diffListener returns the changes between desired and live, and the update that applies them to
+// the version of live. The protocol cannot be updated in place, changing it is reported as an error.
+func diffListener(desired *manifestListener, live *hlb.Listener) ([]planChange, *hlb.ListenerUpdate, error) {
+	if desired.Protocol != live.Protocol {
+		return nil, nil, fmt.Errorf("listener %d (%s): protocol cannot be changed in place from %s to %s, delete and recreate it", live.Port, live.ID, live.Protocol, desired.Protocol)
+	}
+
+	var changes []planChange
+	update := &hlb.ListenerUpdate{IfMatch: live.ETag()}
+	changed := func(field string, from, to interface{}) bool {
+		if from == to {
+			return false
+		}
+		changes = append(changes, planChange{Field: field, From: from, To: to})
+		return true
+	}
+
+	if changed("targetGroupArn", live.TargetGroupARN, desired.TargetGroupARN) {
+		update.TargetGroupARN = &desired.TargetGroupARN
+	}
+	if changed("certificateSecretsName", live.CertificateSecretsName, desired.CertificateSecretsName) {
+		update.CertificateSecretsName = &desired.CertificateSecretsName
+	}
+	if changed("alpnPolicy", live.ALPNPolicy, desired.ALPNPolicy) {
+		update.ALPNPolicy = &desired.ALPNPolicy
+	}
+	if changed("enableDeletionProtection", live.EnableDeletionProtection, desired.EnableDeletionProtection) {
+		update.EnableDeletionProtection = &desired.EnableDeletionProtection
+	}
+	if changed("overprovisioningFactor", live.OverprovisioningFactor, desired.OverprovisioningFactor) {
+		update.OverprovisioningFactor = &desired.OverprovisioningFactor
+	}
+
+	return changes, update, nil
+}
+
+// mergeLaunchConfig overlays the non-zero values of desired on live
+func mergeLaunchConfig(live, desired *hlb.LaunchConfig) *hlb.LaunchConfig {
+	merged := hlb.LaunchConfig{}
+	if live != nil {
+		merged = *live
+	}
+	if desired.InstanceType != "" {
+		merged.InstanceType = desired.InstanceType
+	}
+	if desired.MinInstanceCount != 0 {
+		merged.MinInstanceCount = desired.MinInstanceCount
+	}
+	if desired.MaxInstanceCount != 0 {
+		merged.MaxInstanceCount = desired.MaxInstanceCount
+	}
+	if desired.TargetCPUUsage != 0 {
+		merged.TargetCPUUsage = desired.TargetCPUUsage
+	}
+	return &merged
+}
+
+func sameStringSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sortedA := append([]string{}, a...)
+	sortedB := append([]string{}, b...)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+	return reflect.DeepEqual(sortedA, sortedB)
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gitlab.guerraz.net/HLB/hlb-terraform-provider/hlb"
+)
+
+func testManifestLoadBalancer(name string, listeners ...manifestListener) manifestLoadBalancer {
+	return manifestLoadBalancer{
+		Name:                         name,
+		ZoneID:                       "Z0123456789ABCDEFGHIJ",
+		ZoneName:                     "example.com",
+		Subnets:                      []string{"subnet-a", "subnet-b"},
+		IPAddressType:                hlb.LBIpAddressTypeV4Only,
+		Ec2IamRole:                   hlb.LBEc2IamRoleStandard,
+		EnableCrossZoneLoadBalancing: hlb.LBCrossAZPolicyAvoid,
+		EnableHttp2:                  true,
+		IdleTimeout:                  60,
+		ClientKeepAlive:              3600,
+		ConnectionDrainingTimeout:    10,
+		XffHeaderProcessingMode:      "append",
+		Listeners:                    listeners,
+	}
+}
+
+// testLiveLoadBalancer returns the live load balancer matching testManifestLoadBalancer
+func testLiveLoadBalancer(id, name string) hlb.LoadBalancer {
+	return hlb.LoadBalancer{
+		ID:                           id,
+		Name:                         name,
+		State:                        hlb.LBStateActive,
+		ZoneID:                       "Z0123456789ABCDEFGHIJ",
+		ZoneName:                     "example.com",
+		Subnets:                      []string{"subnet-b", "subnet-a"},
+		IPAddressType:                hlb.LBIpAddressTypeV4Only,
+		Ec2IamRole:                   hlb.LBEc2IamRoleStandard,
+		EnableCrossZoneLoadBalancing: hlb.LBCrossAZPolicyAvoid,
+		EnableHttp2:                  true,
+		IdleTimeout:                  60,
+		ClientKeepAlive:              3600,
+		ConnectionDrainingTimeout:    10,
+		XffHeaderProcessingMode:      "append",
+	}
+}
+
+func testManifestListener(port int) manifestListener {
+	return manifestListener{Port: port, Protocol: "HTTP", TargetGroupARN: "arn:tg", OverprovisioningFactor: 1.1}
+}
+
+func testLiveListener(id string, port int) hlb.Listener {
+	return hlb.Listener{ID: id, Port: port, Protocol: "HTTP", TargetGroupARN: "arn:tg", OverprovisioningFactor: 1.1}
+}
+
+// summarizePlan renders the actions of plan as one line per resource
+func summarizePlan(plan *applyPlan) []string {
+	var lines []string
+	for _, lb := range plan.LoadBalancers {
+		lines = append(lines, strings.TrimRight(fmt.Sprintf("%s %s %s", lb.Action, lb.Name, lb.ID), " "))
+		for _, c := range lb.Changes {
+			lines = append(lines, fmt.Sprintf("  %s: %v -> %v", c.Field, c.From, c.To))
+		}
+		for _, l := range lb.Listeners {
+			lines = append(lines, strings.TrimRight(fmt.Sprintf("  %s %d %s", l.Action, l.Port, l.ID), " "))
+			for _, c := range l.Changes {
+				lines = append(lines, fmt.Sprintf("    %s: %v -> %v", c.Field, c.From, c.To))
+			}
+		}
+	}
+	return lines
+}
+
+func TestBuildPlan(t *testing.T) {
+	updatedWeb := testManifestLoadBalancer("web", testManifestListener(80))
+	updatedWeb.IdleTimeout = 120
+	updatedListener := testManifestListener(80)
+	updatedListener.TargetGroupARN = "arn:other"
+	deleting := testLiveLoadBalancer("lb-3", "web")
+	deleting.State = hlb.LBStateDeleting
+	movedWeb := testManifestLoadBalancer("web")
+	movedWeb.Subnets = []string{"subnet-c"}
+	changedWeb := testLiveLoadBalancer("lb-1", "web")
+	changedWeb.IdleTimeout = 90
+	updatedWebNow := testLiveLoadBalancer("lb-1", "web")
+	updatedWebNow.IdleTimeout = 120
+	changedListener := testLiveListener("lis-1", 80)
+	changedListener.TargetGroupARN = "arn:changed"
+
+	tests := []struct {
+		name      string
+		manifest  []manifestLoadBalancer
+		live      []hlb.LoadBalancer
+		listeners map[string][]hlb.Listener
+		// current holds the resources returned when read again, by ID, when they changed since
+		// they were listed
+		current   map[string]interface{}
+		prune     bool
+		want      []string
+		wantReads []string
+		wantErr   string
+	}{
+		{
+			name:     "create",
+			manifest: []manifestLoadBalancer{testManifestLoadBalancer("web", testManifestListener(80), testManifestListener(443))},
+			want:     []string{"create web", "  create 80", "  create 443"},
+		},
+		{
+			name:      "no changes",
+			manifest:  []manifestLoadBalancer{testManifestLoadBalancer("web", testManifestListener(80))},
+			live:      []hlb.LoadBalancer{testLiveLoadBalancer("lb-1", "web")},
+			listeners: map[string][]hlb.Listener{"lb-1": {testLiveListener("lis-1", 80)}},
+			want:      []string{"no-op web lb-1", "  no-op 80 lis-1"},
+		},
+		{
+			name:      "update load balancer",
+			manifest:  []manifestLoadBalancer{updatedWeb},
+			live:      []hlb.LoadBalancer{testLiveLoadBalancer("lb-1", "web")},
+			listeners: map[string][]hlb.Listener{"lb-1": {testLiveListener("lis-1", 80)}},
+			want:      []string{"update web lb-1", "  idleTimeout: 60 -> 120", "  no-op 80 lis-1"},
+			wantReads: []string{"lb-1"},
+		},
+		{
+			name:      "updates are planned from the version read again",
+			manifest:  []manifestLoadBalancer{updatedWeb},
+			live:      []hlb.LoadBalancer{testLiveLoadBalancer("lb-1", "web")},
+			listeners: map[string][]hlb.Listener{"lb-1": {testLiveListener("lis-1", 80)}},
+			current:   map[string]interface{}{"lb-1": changedWeb},
+			want:      []string{"update web lb-1", "  idleTimeout: 90 -> 120", "  no-op 80 lis-1"},
+			wantReads: []string{"lb-1"},
+		},
+		{
+			name:      "changes already applied since listed",
+			manifest:  []manifestLoadBalancer{updatedWeb},
+			live:      []hlb.LoadBalancer{testLiveLoadBalancer("lb-1", "web")},
+			listeners: map[string][]hlb.Listener{"lb-1": {testLiveListener("lis-1", 80)}},
+			current:   map[string]interface{}{"lb-1": updatedWebNow},
+			want:      []string{"no-op web lb-1", "  no-op 80 lis-1"},
+			wantReads: []string{"lb-1"},
+		},
+		{
+			name:      "update and create listeners",
+			manifest:  []manifestLoadBalancer{testManifestLoadBalancer("web", testManifestListener(443), updatedListener)},
+			live:      []hlb.LoadBalancer{testLiveLoadBalancer("lb-1", "web")},
+			listeners: map[string][]hlb.Listener{"lb-1": {testLiveListener("lis-1", 80)}},
+			current:   map[string]interface{}{"lis-1": changedListener},
+			want:      []string{"no-op web lb-1", "  update 80 lis-1", "    targetGroupArn: arn:changed -> arn:other", "  create 443"},
+			wantReads: []string{"lb-1/lis-1"},
+		},
+		{
+			name:      "undeclared resources are left alone without prune",
+			manifest:  []manifestLoadBalancer{testManifestLoadBalancer("web")},
+			live:      []hlb.LoadBalancer{testLiveLoadBalancer("lb-1", "web"), testLiveLoadBalancer("lb-2", "api")},
+			listeners: map[string][]hlb.Listener{"lb-1": {testLiveListener("lis-1", 80)}},
+			want:      []string{"no-op web lb-1"},
+		},
+		{
+			name:     "prune deletes undeclared load balancers with their listeners",
+			manifest: []manifestLoadBalancer{testManifestLoadBalancer("web")},
+			live:     []hlb.LoadBalancer{testLiveLoadBalancer("lb-2", "worker"), testLiveLoadBalancer("lb-1", "web"), testLiveLoadBalancer("lb-3", "api")},
+			listeners: map[string][]hlb.Listener{
+				"lb-1": {testLiveListener("lis-1", 80)},
+				"lb-3": {testLiveListener("lis-3", 443), testLiveListener("lis-2", 80)},
+			},
+			prune: true,
+			want:  []string{"no-op web lb-1", "  delete 80 lis-1", "delete api lb-3", "  delete 80 lis-2", "  delete 443 lis-3", "delete worker lb-2"},
+		},
+		{
+			name:     "load balancers being deleted are ignored",
+			manifest: []manifestLoadBalancer{testManifestLoadBalancer("web")},
+			live:     []hlb.LoadBalancer{deleting},
+			prune:    true,
+			want:     []string{"create web"},
+		},
+		{
+			name:     "duplicate names",
+			manifest: []manifestLoadBalancer{testManifestLoadBalancer("web")},
+			live:     []hlb.LoadBalancer{testLiveLoadBalancer("lb-1", "web"), testLiveLoadBalancer("lb-2", "web")},
+			wantErr:  `several load balancers are named "web"`,
+		},
+		{
+			name:     "immutable settings",
+			manifest: []manifestLoadBalancer{movedWeb},
+			live:     []hlb.LoadBalancer{testLiveLoadBalancer("lb-1", "web")},
+			wantErr:  "subnets cannot be changed in place",
+		},
+		{
+			name:      "listener protocol",
+			manifest:  []manifestLoadBalancer{testManifestLoadBalancer("web", manifestListener{Port: 80, Protocol: "UDP", TargetGroupARN: "arn:tg", OverprovisioningFactor: 1.1})},
+			live:      []hlb.LoadBalancer{testLiveLoadBalancer("lb-1", "web")},
+			listeners: map[string][]hlb.Listener{"lb-1": {testLiveListener("lis-1", 80)}},
+			wantErr:   "protocol cannot be changed in place from HTTP to UDP",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reads []string
+			reader := liveReader{
+				listListeners: func(loadBalancerID string) ([]hlb.Listener, error) {
+					return tt.listeners[loadBalancerID], nil
+				},
+				getLoadBalancer: func(id string) (*hlb.LoadBalancer, error) {
+					reads = append(reads, id)
+					if lb, ok := tt.current[id].(hlb.LoadBalancer); ok {
+						return &lb, nil
+					}
+					for _, lb := range tt.live {
+						if lb.ID == id {
+							return &lb, nil
+						}
+					}
+					return nil, fmt.Errorf("load balancer %s not found", id)
+				},
+				getListener: func(loadBalancerID, listenerID string) (*hlb.Listener, error) {
+					reads = append(reads, loadBalancerID+"/"+listenerID)
+					if l, ok := tt.current[listenerID].(hlb.Listener); ok {
+						return &l, nil
+					}
+					for _, l := range tt.listeners[loadBalancerID] {
+						if l.ID == listenerID {
+							return &l, nil
+						}
+					}
+					return nil, fmt.Errorf("listener %s not found", listenerID)
+				},
+			}
+			plan, err := buildPlan(&manifest{LoadBalancers: tt.manifest}, tt.live, reader, tt.prune)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("buildPlan() error = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("buildPlan() error = %v", err)
+			}
+			if got := summarizePlan(plan); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildPlan() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+			if !reflect.DeepEqual(reads, tt.wantReads) {
+				t.Errorf("buildPlan() read %v again, want %v", reads, tt.wantReads)
+			}
+		})
+	}
+}
+
+func TestBuildPlanListListenersError(t *testing.T) {
+	listErr := errors.New("list failed")
+	live := []hlb.LoadBalancer{testLiveLoadBalancer("lb-1", "web")}
+	reader := liveReader{listListeners: func(string) ([]hlb.Listener, error) { return nil, listErr }}
+
+	if _, err := buildPlan(&manifest{}, live, reader, true); !errors.Is(err, listErr) {
+		t.Errorf("buildPlan() error = %v, want %v", err, listErr)
+	}
+}
+
+func TestDiffLoadBalancer(t *testing.T) {
+	tests := []struct {
+		name        string
+		desired     func(m *manifestLoadBalancer)
+		live        func(lb *hlb.LoadBalancer)
+		wantChanges []string
+		wantUpdate  *hlb.LoadBalancerUpdate
+		wantErr     string
+	}{
+		{
+			name:       "no changes",
+			wantUpdate: &hlb.LoadBalancerUpdate{},
+		},
+		{
+			name: "scalar settings",
+			desired: func(m *manifestLoadBalancer) {
+				m.EnableHttp2 = false
+				m.PreferredMaintenanceWindow = "sun:03:00-sun:04:00"
+			},
+			wantChanges: []string{"enableHttp2", "preferredMaintenanceWindow"},
+			wantUpdate:  &hlb.LoadBalancerUpdate{EnableHttp2: ptr(false), PreferredMaintenanceWindow: ptr("sun:03:00-sun:04:00")},
+		},
+		{
+			name: "omitted security groups and tags are unmanaged",
+			live: func(lb *hlb.LoadBalancer) {
+				lb.SecurityGroups = []string{"sg-1"}
+				lb.Tags = map[string]string{"env": "prod"}
+			},
+			wantUpdate: &hlb.LoadBalancerUpdate{},
+		},
+		{
+			name:        "security groups are compared as sets",
+			desired:     func(m *manifestLoadBalancer) { m.SecurityGroups = []string{"sg-2", "sg-1"} },
+			live:        func(lb *hlb.LoadBalancer) { lb.SecurityGroups = []string{"sg-1"} },
+			wantChanges: []string{"securityGroups"},
+			wantUpdate:  &hlb.LoadBalancerUpdate{SecurityGroups: []string{"sg-2", "sg-1"}},
+		},
+		{
+			name:       "empty tags match missing tags",
+			desired:    func(m *manifestLoadBalancer) { m.Tags = map[string]string{} },
+			wantUpdate: &hlb.LoadBalancerUpdate{},
+		},
+		{
+			name:        "empty tags remove tags",
+			desired:     func(m *manifestLoadBalancer) { m.Tags = map[string]string{} },
+			live:        func(lb *hlb.LoadBalancer) { lb.Tags = map[string]string{"env": "prod"} },
+			wantChanges: []string{"tags"},
+			wantUpdate:  &hlb.LoadBalancerUpdate{Tags: &map[string]string{}},
+		},
+		{
+			name:    "unset launch config values are chosen by the API",
+			desired: func(m *manifestLoadBalancer) { m.LaunchConfig = &hlb.LaunchConfig{MinInstanceCount: 2} },
+			live: func(lb *hlb.LoadBalancer) {
+				lb.LaunchConfig = &hlb.LaunchConfig{InstanceType: "t3.small", MinInstanceCount: 2}
+			},
+			wantUpdate: &hlb.LoadBalancerUpdate{},
+		},
+		{
+			name:    "launch config changes keep the API chosen values",
+			desired: func(m *manifestLoadBalancer) { m.LaunchConfig = &hlb.LaunchConfig{MaxInstanceCount: 4} },
+			live: func(lb *hlb.LoadBalancer) {
+				lb.LaunchConfig = &hlb.LaunchConfig{InstanceType: "t3.small", MaxInstanceCount: 2}
+			},
+			wantChanges: []string{"launchConfig"},
+			wantUpdate:  &hlb.LoadBalancerUpdate{LaunchConfig: &hlb.LaunchConfig{InstanceType: "t3.small", MaxInstanceCount: 4}},
+		},
+		{
+			name:    "immutable settings",
+			desired: func(m *manifestLoadBalancer) { m.Internal = true; m.ZoneName = "example.org" },
+			wantErr: "internal, zoneName cannot be changed in place",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			desired := testManifestLoadBalancer("web")
+			if tt.desired != nil {
+				tt.desired(&desired)
+			}
+			live := testLiveLoadBalancer("lb-1", "web")
+			if tt.live != nil {
+				tt.live(&live)
+			}
+
+			changes, update, err := diffLoadBalancer(&desired, &live)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("diffLoadBalancer() error = %v, want an error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("diffLoadBalancer() error = %v", err)
+			}
+
+			var fields []string
+			for _, c := range changes {
+				fields = append(fields, c.Field)
+			}
+			if !reflect.DeepEqual(fields, tt.wantChanges) {
+				t.Errorf("diffLoadBalancer() changes = %v, want %v", fields, tt.wantChanges)
+			}
+			if !reflect.DeepEqual(update, tt.wantUpdate) {
+				t.Errorf("diffLoadBalancer() update = %+v, want %+v", update, tt.wantUpdate)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
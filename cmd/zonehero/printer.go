@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	outputText       = "text"
+	outputWide       = "wide"
+	outputJSON       = "json"
+	outputYAML       = "yaml"
+	outputJSONPath   = "jsonpath"
+	outputGoTemplate = "go-template"
+)
+
+// printer renders command results in the format selected with the global --output flag. Every
+// command prints through a printer so that all output formats are available everywhere.
+type printer struct {
+	out    io.Writer
+	format string
+	// expression is the argument of the jsonpath and go-template formats
+	expression string
+}
+
+// table describes how a list of items is rendered in the text and wide formats
+type table struct {
+	headers []string
+	rows    [][]string
+	// wideHeaders and wideRows are appended to headers and rows in the wide format
+	wideHeaders []string
+	wideRows    [][]string
+}
+
+// describer writes the detailed text view of a single object
+type describer func(w io.Writer)
+
+func newPrinter() (*printer, error) {
+	format, expression, _ := strings.Cut(output, "=")
+	switch format {
+	case outputText, outputWide, outputJSON, outputYAML:
+		if expression != "" {
+			return nil, fmt.Errorf("output format %q does not take an argument", format)
+		}
+	case outputJSONPath, outputGoTemplate:
+		if expression == "" {
+			return nil, fmt.Errorf("output format %q requires an argument, e.g. -o %s=...", format, format)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported output format %q: must be one of text, wide, json, yaml, jsonpath=..., go-template=...", output)
+	}
+	return &printer{out: os.Stdout, format: format, expression: expression}, nil
+}
+
+// isStructured reports whether the selected format renders data rather than text views
+func (p *printer) isStructured() bool {
+	return p.format != outputText && p.format != outputWide
+}
+
+// printList prints a list of items. Structured formats receive data, text formats the table.
+func (p *printer) printList(data interface{}, t *table) error {
+	if p.isStructured() {
+		return p.printData(data)
+	}
+
+	w := tabwriter.NewWriter(p.out, 0, 0, 2, ' ', 0)
+	headers := t.headers
+	if p.format == outputWide {
+		headers = append(append([]string{}, headers...), t.wideHeaders...)
+	}
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+	for i, row := range t.rows {
+		if p.format == outputWide && i < len(t.wideRows) {
+			row = append(append([]string{}, row...), t.wideRows[i]...)
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
+
+// printObject prints a single object, using the describe view in the text formats
+func (p *printer) printObject(data interface{}, describe describer) error {
+	if p.isStructured() {
+		return p.printData(data)
+	}
+	describe(p.out)
+	return nil
+}
+
+// printMessage prints data in structured formats and a human readable message otherwise
+func (p *printer) printMessage(data interface{}, format string, args ...interface{}) error {
+	if p.isStructured() {
+		return p.printData(data)
+	}
+	_, err := fmt.Fprintf(p.out, format+"\n", args...)
+	return err
+}
+
+// events returns the writer for progress messages, which go to stderr in structured formats to
+// keep stdout machine readable
+func (p *printer) events() io.Writer {
+	if p.isStructured() {
+		return os.Stderr
+	}
+	return p.out
+}
+
+// printNextToken tells text users how to fetch the next page of a paginated list
+func (p *printer) printNextToken(nextToken string) {
+	if nextToken != "" && !p.isStructured() {
+		fmt.Fprintf(p.out, "\nUse --next-token '%s' to get the next page\n", nextToken)
+	}
+}
+
+func (p *printer) printData(data interface{}) error {
+	switch p.format {
+	case outputJSON:
+		return json.NewEncoder(p.out).Encode(data)
+	}
+
+	// The remaining formats address fields by their JSON names, so work on the generic
+	// representation of data
+	generic, err := toGeneric(data)
+	if err != nil {
+		return err
+	}
+
+	switch p.format {
+	case outputYAML:
+		enc := yaml.NewEncoder(p.out)
+		enc.SetIndent(2)
+		if err := enc.Encode(generic); err != nil {
+			return fmt.Errorf("failed to encode YAML output: %w", err)
+		}
+		return enc.Close()
+	case outputJSONPath:
+		return executeJSONPath(p.out, p.expression, data)
+	case outputGoTemplate:
+		tmpl, err := template.New("output").Parse(p.expression)
+		if err != nil {
+			return fmt.Errorf("invalid go-template: %w", err)
+		}
+		if err := tmpl.Execute(p.out, generic); err != nil {
+			return fmt.Errorf("failed to execute go-template: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported output format %q", p.format)
+}
+
+// toGeneric converts data to maps, slices and scalars keyed by JSON field names
+func toGeneric(data interface{}) (interface{}, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode output: %w", err)
+	}
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return nil, fmt.Errorf("failed to encode output: %w", err)
+	}
+	return generic, nil
+}
+
+// describeWriter writes aligned "Key: value" lines for describe views
+type describeWriter struct {
+	w      *tabwriter.Writer
+	indent string
+}
+
+func newDescribeWriter(out io.Writer) *describeWriter {
+	return &describeWriter{w: tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)}
+}
+
+func (d *describeWriter) field(name string, value interface{}) {
+	fmt.Fprintf(d.w, "%s%s:\t%v\n", d.indent, name, value)
+}
+
+func (d *describeWriter) section(name string) {
+	fmt.Fprintf(d.w, "%s%s:\n", d.indent, name)
+}
+
+func (d *describeWriter) nested(fn func()) {
+	previous := d.indent
+	d.indent += "  "
+	fn()
+	d.indent = previous
+}
+
+func (d *describeWriter) flush() {
+	d.w.Flush()
+}
+
+// orNone renders empty values in describe views and tables
+func orNone(value string) string {
+	if value == "" {
+		return "<none>"
+	}
+	return value
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"gitlab.guerraz.net/HLB/hlb-terraform-provider/hlb"
+)
+
+// selectorFlags are the flags registered by addSelectorFlags
+var selectorFlags = []string{"selector", "name-regex", "state", "internal"}
+
+// selectorRequirement is a single tag requirement of a selector such as env=prod, env!=prod,
+// env (tag present) or !env (tag absent)
+type selectorRequirement struct {
+	key     string
+	value   string
+	negate  bool
+	hasTest bool
+}
+
+// loadBalancerFilter selects load balancers by tags, name, state and scheme
+type loadBalancerFilter struct {
+	requirements []selectorRequirement
+	nameRegex    *regexp.Regexp
+	states       []string
+	internal     *bool
+}
+
+func addSelectorFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("selector", "l", "", "Tag selector, e.g. 'team=payments,env!=prod,owner,!temporary'")
+	cmd.Flags().String("name-regex", "", "Only select load balancers whose name matches this regular expression")
+	cmd.Flags().StringSlice("state", []string{}, "Only select load balancers in these states")
+	cmd.Flags().Bool("internal", false, "Only select internal (true) or internet-facing (false) load balancers")
+}
+
+// addBulkSelectionFlags registers the selector flags on a command acting on the load balancer
+// named by --id, so that it can act on every matching load balancer instead, and --yes to skip
+// the confirmation of bulk actions
+func addBulkSelectionFlags(cmd *cobra.Command) {
+	addSelectorFlags(cmd)
+	cmd.Flags().BoolP("yes", "y", false, "Do not ask for confirmation")
+	cmd.MarkFlagsOneRequired(append([]string{"id"}, selectorFlags...)...)
+	for _, name := range selectorFlags {
+		cmd.MarkFlagsMutuallyExclusive("id", name)
+	}
+}
+
+// parseSelector parses a comma separated list of tag requirements
+func parseSelector(selector string) ([]selectorRequirement, error) {
+	var requirements []selectorRequirement
+	for _, part := range strings.Split(selector, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		var r selectorRequirement
+		switch {
+		case strings.Contains(part, "!="):
+			r.key, r.value, _ = strings.Cut(part, "!=")
+			r.negate, r.hasTest = true, true
+		case strings.Contains(part, "=="):
+			r.key, r.value, _ = strings.Cut(part, "==")
+			r.hasTest = true
+		case strings.Contains(part, "="):
+			r.key, r.value, _ = strings.Cut(part, "=")
+			r.hasTest = true
+		case strings.HasPrefix(part, "!"):
+			r.key = strings.TrimPrefix(part, "!")
+			r.negate = true
+		default:
+			r.key = part
+		}
+
+		r.key = strings.TrimSpace(r.key)
+		r.value = strings.TrimSpace(r.value)
+		if r.key == "" {
+			return nil, fmt.Errorf("invalid selector %q: missing tag key in %q", selector, part)
+		}
+		requirements = append(requirements, r)
+	}
+	return requirements, nil
+}
+
+func (r selectorRequirement) matches(tags map[string]string) bool {
+	value, ok := tags[r.key]
+	if !r.hasTest {
+		return ok != r.negate
+	}
+	if r.negate {
+		return !ok || value != r.value
+	}
+	return ok && value == r.value
+}
+
+// filterFromFlags returns the filter described by the selector flags, or nil if none is set
+func filterFromFlags(cmd *cobra.Command) (*loadBalancerFilter, error) {
+	flags := cmd.Flags()
+	if !anyFlagChanged(cmd, selectorFlags...) {
+		return nil, nil
+	}
+
+	f := &loadBalancerFilter{}
+	var err error
+
+	selector, _ := flags.GetString("selector")
+	if f.requirements, err = parseSelector(selector); err != nil {
+		return nil, err
+	}
+
+	if pattern, _ := flags.GetString("name-regex"); pattern != "" {
+		if f.nameRegex, err = regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("invalid name-regex: %w", err)
+		}
+	}
+
+	f.states, _ = flags.GetStringSlice("state")
+
+	if flags.Changed("internal") {
+		internal, _ := flags.GetBool("internal")
+		f.internal = &internal
+	}
+
+	return f, nil
+}
+
+func (f *loadBalancerFilter) matches(lb *hlb.LoadBalancer) bool {
+	for _, r := range f.requirements {
+		if !r.matches(lb.Tags) {
+			return false
+		}
+	}
+	if f.nameRegex != nil && !f.nameRegex.MatchString(lb.Name) {
+		return false
+	}
+	if len(f.states) > 0 && !slices.Contains(f.states, lb.State) {
+		return false
+	}
+	if f.internal != nil && lb.Internal != *f.internal {
+		return false
+	}
+	return true
+}
+
+func (f *loadBalancerFilter) filter(loadBalancers []hlb.LoadBalancer) []hlb.LoadBalancer {
+	matched := []hlb.LoadBalancer{}
+	for i := range loadBalancers {
+		if f.matches(&loadBalancers[i]) {
+			matched = append(matched, loadBalancers[i])
+		}
+	}
+	return matched
+}
+
+// selectLoadBalancers returns the load balancer named by --id, or every load balancer matching the
+// selector flags. bulk reports whether the selector flags were used.
+func selectLoadBalancers(cmd *cobra.Command, client *hlb.Client) (selected []hlb.LoadBalancer, bulk bool, err error) {
+	f, err := filterFromFlags(cmd)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if f == nil {
+		id, _ := cmd.Flags().GetString("id")
+		lb, err := client.GetLoadBalancer(cmd.Context(), id)
+		if err != nil {
+			return nil, false, err
+		}
+		return []hlb.LoadBalancer{*lb}, false, nil
+	}
+
+	all, err := listAllLoadBalancers(cmd.Context(), client)
+	if err != nil {
+		return nil, true, err
+	}
+
+	// Deleted load balancers are only selected when asked for explicitly
+	if len(f.states) == 0 {
+		live := all[:0]
+		for _, lb := range all {
+			if isLiveLoadBalancerState(lb.State) {
+				live = append(live, lb)
+			}
+		}
+		all = live
+	}
+
+	return f.filter(all), true, nil
+}
+
+// confirmBulk summarizes the load balancers an action applies to and asks for confirmation,
+// unless --yes is set
+func confirmBulk(cmd *cobra.Command, action string, loadBalancers []hlb.LoadBalancer) error {
+	w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSTATE\tTAGS")
+	for _, lb := range loadBalancers {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", lb.ID, lb.Name, lb.State, orNone(formatTags(lb.Tags)))
+	}
+	w.Flush()
+
+	return confirm(cmd, fmt.Sprintf("%s %d load balancers?", action, len(loadBalancers)))
+}
+
+// confirm asks a yes/no question on the terminal and returns errAborted when it is declined. It
+// does not ask when --yes or --dry-run is set, and fails when stdin is not a terminal.
+func confirm(cmd *cobra.Command, question string) error {
+	if yes, _ := cmd.Flags().GetBool("yes"); yes || dryRun {
+		return nil
+	}
+	if !isTerminal(os.Stdin) {
+		return fmt.Errorf("%s Refusing to proceed without confirmation, use --yes in non-interactive sessions", question)
+	}
+	ok, err := askConfirmation(os.Stdin, os.Stderr, question)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errAborted
+	}
+	return nil
+}
+
+func askConfirmation(in io.Reader, out io.Writer, question string) (bool, error) {
+	fmt.Fprintf(out, "%s [y/N]: ", question)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+// formatTags renders tags as sorted key=value pairs
+func formatTags(tags map[string]string) string {
+	keys := slices.Sorted(maps.Keys(tags))
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + tags[k]
+	}
+	return strings.Join(pairs, ",")
+}
+
+func anyFlagChanged(cmd *cobra.Command, names ...string) bool {
+	for _, name := range names {
+		if cmd.Flags().Changed(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+
+	"github.com/spf13/cobra"
+	"gitlab.guerraz.net/HLB/hlb-terraform-provider/hlb"
+)
+
+func init() {
+	// Tag Commands
+	hlbCmd.AddCommand(tagCmd)
+	hlbCmd.AddCommand(untagCmd)
+
+	// Tag Flags
+	tagCmd.Flags().String("id", "", "ID of the load balancer to tag")
+	tagCmd.Flags().StringArray("tag", []string{}, "Tag to add or overwrite in the format key=value (can be repeated)")
+	tagCmd.MarkFlagRequired("tag")
+	addBulkSelectionFlags(tagCmd)
+
+	// Untag Flags
+	untagCmd.Flags().String("id", "", "ID of the load balancer to untag")
+	untagCmd.Flags().StringArray("key", []string{}, "Key of the tag to remove (can be repeated)")
+	untagCmd.MarkFlagRequired("key")
+	addBulkSelectionFlags(untagCmd)
+}
+
+var tagCmd = &cobra.Command{
+	Use:   "tag",
+	Short: "Add tags to load balancers",
+	Long: `Add or overwrite tags on the load balancer named by --id, or on every load balancer matching the
+selector flags. Other tags are kept.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tagValues, _ := cmd.Flags().GetStringArray("tag")
+		added, err := parseTags(tagValues)
+		if err != nil {
+			return err
+		}
+
+		return retagLoadBalancers(cmd, "Tag", func(tags map[string]string) {
+			maps.Copy(tags, added)
+		})
+	},
+}
+
+var untagCmd = &cobra.Command{
+	Use:   "untag",
+	Short: "Remove tags from load balancers",
+	Long: `Remove tags from the load balancer named by --id, or from every load balancer matching the
+selector flags. Other tags are kept.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		keys, _ := cmd.Flags().GetStringArray("key")
+
+		return retagLoadBalancers(cmd, "Untag", func(tags map[string]string) {
+			for _, key := range keys {
+				delete(tags, key)
+			}
+		})
+	},
+}
+
+// retagLoadBalancers applies edit to the tags of the selected load balancers and updates the ones
+// whose tags changed
+func retagLoadBalancers(cmd *cobra.Command, action string, edit func(tags map[string]string)) error {
+	client, err := createClient(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	targets, bulk, err := selectLoadBalancers(cmd, client)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return outputPrinter.printMessage(map[string]interface{}{"items": targets}, "No load balancers match the selector")
+	}
+	if bulk {
+		if err := confirmBulk(cmd, action, targets); err != nil {
+			return err
+		}
+	}
+
+	updated := []*hlb.LoadBalancer{}
+	for i := range targets {
+		target := &targets[i]
+		tags := maps.Clone(target.Tags)
+		if tags == nil {
+			tags = map[string]string{}
+		}
+		edit(tags)
+		if maps.Equal(tags, target.Tags) {
+			fmt.Fprintf(outputPrinter.events(), "Unchanged load balancer: %s (ID: %s)\n", target.Name, target.ID)
+			continue
+		}
+
+		lb, err := client.UpdateLoadBalancer(cmd.Context(), target.ID, &hlb.LoadBalancerUpdate{Tags: &tags})
+		if err != nil {
+			return fmt.Errorf("failed to update tags of load balancer %s (%s): %w", target.Name, target.ID, err)
+		}
+		fmt.Fprintf(outputPrinter.events(), "Updated tags of load balancer: %s (ID: %s) %s\n", lb.Name, lb.ID, orNone(formatTags(tags)))
+		updated = append(updated, lb)
+	}
+
+	return outputPrinter.printMessage(map[string]interface{}{"items": updated}, "Updated tags of %d load balancers", len(updated))
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gitlab.guerraz.net/HLB/hlb-terraform-provider/hlb"
+)
+
+// The validators below mirror the ones declared in the Terraform provider schema so that the CLI
+// rejects the same values before calling the API.
+
+var (
+	validEc2IamRoles       = []string{hlb.LBEc2IamRoleStandard, hlb.LBEc2IamRoleDebug}
+	validIPAddressTypes    = []string{hlb.LBIpAddressTypeV4Only, hlb.LBIpAddressDualStack, hlb.LBIpAddressTypeV6Only}
+	validCrossZoneModes    = []string{hlb.LBCrossAZPolicyFull, hlb.LBCrossAZPolicyAvoid, hlb.LBCrossAZPolicyOff}
+	validXffProcessingMode = []string{"append", "preserve", "remove"}
+
+	maintenanceTimeRangeRegex = regexp.MustCompile(`^([0-1][0-9]|2[0-3]):([0-5][0-9])-([0-1][0-9]|2[0-3]):([0-5][0-9])$`)
+)
+
+func validateOneOf(name, value string, valid []string) error {
+	for _, v := range valid {
+		if value == v {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid value %q for %s: must be one of %s", value, name, strings.Join(valid, ", "))
+}
+
+func validateIntBetween(name string, value, min, max int) error {
+	if value < min || value > max {
+		return fmt.Errorf("invalid value %d for %s: must be between %d and %d", value, name, min, max)
+	}
+	return nil
+}
+
+func validateLengthBetween(name, value string, min, max int) error {
+	if len(value) < min || len(value) > max {
+		return fmt.Errorf("invalid value %q for %s: length must be between %d and %d", value, name, min, max)
+	}
+	return nil
+}
+
+// validateMaintenanceWindow checks the 'ddd-ddd,hh24:mm-hh24:mm' format, an empty string is valid
+func validateMaintenanceWindow(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid preferred-maintenance-window %q: must be in the format 'ddd-ddd,hh24:mm-hh24:mm'", value)
+	}
+
+	dayParts := strings.Split(parts[0], "-")
+	if len(dayParts) != 2 {
+		return fmt.Errorf("invalid preferred-maintenance-window %q: the day range must be in the format 'ddd-ddd' (e.g., 'mon-fri')", value)
+	}
+	validDays := []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}
+	for _, day := range dayParts {
+		if err := validateOneOf("preferred-maintenance-window day", strings.ToLower(day), validDays); err != nil {
+			return err
+		}
+	}
+
+	if !maintenanceTimeRangeRegex.MatchString(parts[1]) {
+		return fmt.Errorf("invalid preferred-maintenance-window %q: the time range must be in the format 'hh24:mm-hh24:mm'", value)
+	}
+	return nil
+}
+
+func validateLaunchConfig(lc *hlb.LaunchConfig) error {
+	if lc == nil {
+		return nil
+	}
+	if lc.MinInstanceCount != 0 && lc.MinInstanceCount < 1 {
+		return fmt.Errorf("invalid launch-config min %d: must be at least 1", lc.MinInstanceCount)
+	}
+	if lc.MaxInstanceCount != 0 && lc.MaxInstanceCount < 1 {
+		return fmt.Errorf("invalid launch-config max %d: must be at least 1", lc.MaxInstanceCount)
+	}
+	if lc.TargetCPUUsage != 0 {
+		if err := validateIntBetween("launch-config target-cpu", lc.TargetCPUUsage, 10, 90); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateAccessLogs(logs *hlb.AccessLogs) error {
+	if logs != nil && logs.Bucket == "" {
+		return fmt.Errorf("access-logs-bucket is required when configuring access logs")
+	}
+	return nil
+}
+
+func validateLoadBalancerCreate(input *hlb.LoadBalancerCreate) error {
+	switch {
+	case input.Name != "" && input.NamePrefix != "":
+		return fmt.Errorf("name and name-prefix cannot be used together")
+	case input.Name == "" && input.NamePrefix == "":
+		return fmt.Errorf("name or name-prefix is required")
+	case input.NamePrefix != "":
+		if err := validateLengthBetween("name-prefix", input.NamePrefix, 1, 6); err != nil {
+			return err
+		}
+	default:
+		if err := validateLengthBetween("name", input.Name, 1, 32); err != nil {
+			return err
+		}
+	}
+	if len(input.Subnets) == 0 {
+		return fmt.Errorf("at least one subnet is required")
+	}
+	if input.ZoneID == "" || input.ZoneName == "" {
+		return fmt.Errorf("zone-id and zone-name are required")
+	}
+	// Zero values are left for the API to default, as with JSON input that omits them
+	for _, check := range []struct {
+		name  string
+		value string
+		valid []string
+	}{
+		{"ec2-iam-role", input.Ec2IamRole, validEc2IamRoles},
+		{"ip-address-type", input.IPAddressType, validIPAddressTypes},
+		{"enable-cross-zone-load-balancing", input.EnableCrossZoneLoadBalancing, validCrossZoneModes},
+		{"xff-header-processing-mode", input.XffHeaderProcessingMode, validXffProcessingMode},
+	} {
+		if check.value == "" {
+			continue
+		}
+		if err := validateOneOf(check.name, check.value, check.valid); err != nil {
+			return err
+		}
+	}
+	if input.IdleTimeout != 0 {
+		if err := validateIntBetween("idle-timeout", input.IdleTimeout, 1, 4000); err != nil {
+			return err
+		}
+	}
+	if input.ClientKeepAlive != 0 {
+		if err := validateIntBetween("client-keep-alive", input.ClientKeepAlive, 60, 604800); err != nil {
+			return err
+		}
+	}
+	if err := validateIntBetween("connection-draining-timeout", input.ConnectionDrainingTimeout, 0, 120); err != nil {
+		return err
+	}
+	if err := validateMaintenanceWindow(input.PreferredMaintenanceWindow); err != nil {
+		return err
+	}
+	if err := validateAccessLogs(input.AccessLogs); err != nil {
+		return err
+	}
+	return validateLaunchConfig(input.LaunchConfig)
+}
+
+func validateLoadBalancerUpdate(input *hlb.LoadBalancerUpdate) error {
+	if input.Name != nil {
+		if err := validateLengthBetween("name", *input.Name, 1, 32); err != nil {
+			return err
+		}
+	}
+	if input.Ec2IamRole != nil {
+		if err := validateOneOf("ec2-iam-role", *input.Ec2IamRole, validEc2IamRoles); err != nil {
+			return err
+		}
+	}
+	if input.EnableCrossZoneLoadBalancing != nil {
+		if err := validateOneOf("enable-cross-zone-load-balancing", *input.EnableCrossZoneLoadBalancing, validCrossZoneModes); err != nil {
+			return err
+		}
+	}
+	if input.XffHeaderProcessingMode != nil {
+		if err := validateOneOf("xff-header-processing-mode", *input.XffHeaderProcessingMode, validXffProcessingMode); err != nil {
+			return err
+		}
+	}
+	if input.IdleTimeout != nil {
+		if err := validateIntBetween("idle-timeout", *input.IdleTimeout, 1, 4000); err != nil {
+			return err
+		}
+	}
+	if input.ClientKeepAlive != nil {
+		if err := validateIntBetween("client-keep-alive", *input.ClientKeepAlive, 60, 604800); err != nil {
+			return err
+		}
+	}
+	if input.ConnectionDrainingTimeout != nil {
+		if err := validateIntBetween("connection-draining-timeout", *input.ConnectionDrainingTimeout, 0, 120); err != nil {
+			return err
+		}
+	}
+	if input.PreferredMaintenanceWindow != nil {
+		if err := validateMaintenanceWindow(*input.PreferredMaintenanceWindow); err != nil {
+			return err
+		}
+	}
+	if err := validateAccessLogs(input.AccessLogs); err != nil {
+		return err
+	}
+	return validateLaunchConfig(input.LaunchConfig)
+}
+
+// parseTags parses repeated --tag key=value flags
+func parseTags(values []string) (map[string]string, error) {
+	tags := make(map[string]string, len(values))
+	for _, v := range values {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid tag %q: must be in the format key=value", v)
+		}
+		tags[key] = value
+	}
+	return tags, nil
+}
+
+// parseLaunchConfig applies a --launch-config specification such as
+// "instance-type=c7g.large,min=2,max=6,target-cpu=50" on top of base. The bounds of the values are
+// checked with the rest of the input by validateLaunchConfig.
+func parseLaunchConfig(spec string, base *hlb.LaunchConfig) (*hlb.LaunchConfig, error) {
+	lc := &hlb.LaunchConfig{}
+	if base != nil {
+		*lc = *base
+	}
+
+	for _, field := range strings.Split(spec, ",") {
+		if field == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid launch-config field %q: must be in the format key=value", field)
+		}
+
+		if key == "instance-type" {
+			lc.InstanceType = value
+			continue
+		}
+
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid launch-config %s %q: must be an integer", key, value)
+		}
+		switch key {
+		case "min":
+			lc.MinInstanceCount = n
+		case "max":
+			lc.MaxInstanceCount = n
+		case "target-cpu":
+			lc.TargetCPUUsage = n
+		default:
+			return nil, fmt.Errorf("unknown launch-config field %q: valid fields are instance-type, min, max and target-cpu", key)
+		}
+	}
+
+	return lc, nil
+}
+
+var (
+	validListenerProtocols = []string{"HTTP", "HTTPS", "UDP"}
+	validALPNPolicies      = []string{"HTTP1Only", "HTTP2Only", "HTTP2Optional", "HTTP2Preferred", "None"}
+)
+
+// validateListenerSettings checks the settings of a listener as they will be after the request is applied
+func validateListenerSettings(port int, protocol, certificateSecretsName, alpnPolicy string, overprovisioningFactor float64) error {
+	if err := validateIntBetween("port", port, 1, 65535); err != nil {
+		return err
+	}
+	if err := validateOneOf("protocol", protocol, validListenerProtocols); err != nil {
+		return err
+	}
+	if alpnPolicy != "" {
+		if err := validateOneOf("alpn-policy", alpnPolicy, validALPNPolicies); err != nil {
+			return err
+		}
+	}
+	if overprovisioningFactor != 0 && overprovisioningFactor < 1.0 {
+		return fmt.Errorf("invalid value %g for overprovisioning-factor: must be at least 1.0", overprovisioningFactor)
+	}
+
+	if protocol == "HTTPS" && certificateSecretsName == "" {
+		return fmt.Errorf("certificate-secrets-name is required when protocol is HTTPS")
+	}
+	if protocol != "HTTPS" && certificateSecretsName != "" {
+		return fmt.Errorf("certificate-secrets-name can only be set when protocol is HTTPS")
+	}
+	if protocol != "HTTPS" && alpnPolicy != "" && alpnPolicy != "None" {
+		return fmt.Errorf("alpn-policy can only be set when protocol is HTTPS")
+	}
+	return nil
+}
+
+func validateListenerCreate(input *hlb.ListenerCreate) error {
+	return validateListenerSettings(input.Port, input.Protocol, input.CertificateSecretsName, input.ALPNPolicy, input.OverprovisioningFactor)
+}
+
+// validateListenerUpdate validates input merged on top of the current listener settings. The
+// protocol cannot be changed, only set to the current one.
+func validateListenerUpdate(current *hlb.Listener, input *hlb.ListenerUpdate) error {
+	if input.Protocol != nil && *input.Protocol != current.Protocol {
+		return fmt.Errorf("protocol cannot be changed from %s to %s, create a new listener instead", current.Protocol, *input.Protocol)
+	}
+
+	merged := *current
+	if input.Port != nil {
+		merged.Port = *input.Port
+	}
+	if input.CertificateSecretsName != nil {
+		merged.CertificateSecretsName = *input.CertificateSecretsName
+	}
+	if input.ALPNPolicy != nil {
+		merged.ALPNPolicy = *input.ALPNPolicy
+	}
+	if input.OverprovisioningFactor != nil {
+		merged.OverprovisioningFactor = *input.OverprovisioningFactor
+	}
+	return validateListenerSettings(merged.Port, merged.Protocol, merged.CertificateSecretsName, merged.ALPNPolicy, merged.OverprovisioningFactor)
+}
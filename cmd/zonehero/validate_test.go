@@ -0,0 +1,39 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"gitlab.guerraz.net/HLB/hlb-terraform-provider/hlb"
+)
+
+func TestValidateListenerUpdate(t *testing.T) {
+	current := &hlb.Listener{ID: "lis-1", Port: 443, Protocol: "HTTPS", CertificateSecretsName: "web-cert"}
+
+	tests := []struct {
+		name    string
+		input   hlb.ListenerUpdate
+		wantErr string
+	}{
+		{name: "same protocol", input: hlb.ListenerUpdate{Protocol: ptr("HTTPS")}},
+		{name: "port", input: hlb.ListenerUpdate{Port: ptr(8443)}},
+		{name: "protocol", input: hlb.ListenerUpdate{Protocol: ptr("HTTP")}, wantErr: "protocol cannot be changed from HTTPS to HTTP"},
+		{name: "certificate cleared", input: hlb.ListenerUpdate{CertificateSecretsName: ptr("")}, wantErr: "certificate-secrets-name is required"},
+		{name: "alpn policy", input: hlb.ListenerUpdate{ALPNPolicy: ptr("HTTP3")}, wantErr: "alpn-policy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateListenerUpdate(current, &tt.input)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("validateListenerUpdate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateListenerUpdate() error = %v, want an error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
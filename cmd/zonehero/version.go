@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	runtimedebug "runtime/debug"
+
+	"github.com/spf13/cobra"
+)
+
+// Set by goreleaser
+var (
+	version = "dev"
+	commit  = ""
+	date    = ""
+)
+
+// buildInfo describes the zonehero binary
+type buildInfo struct {
+	Version   string `json:"version" yaml:"version"`
+	Commit    string `json:"commit,omitempty" yaml:"commit,omitempty"`
+	Date      string `json:"date,omitempty" yaml:"date,omitempty"`
+	GoVersion string `json:"goVersion" yaml:"goVersion"`
+	Platform  string `json:"platform" yaml:"platform"`
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+}
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version of zonehero",
+	Args:  cobra.NoArgs,
+	// The version is printed even when the configuration is invalid
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		var err error
+		outputPrinter, err = newPrinter()
+		return err
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		info := currentBuildInfo()
+		return outputPrinter.printObject(info, func(w io.Writer) {
+			d := newDescribeWriter(w)
+			defer d.flush()
+
+			d.field("Version", info.Version)
+			d.field("Commit", orNone(info.Commit))
+			d.field("Built", orNone(info.Date))
+			d.field("Go Version", info.GoVersion)
+			d.field("Platform", info.Platform)
+		})
+	},
+}
+
+// currentBuildInfo returns the build information set by goreleaser, falling back to the
+// information recorded by the Go toolchain for binaries built with go install or go build
+func currentBuildInfo() buildInfo {
+	info := buildInfo{
+		Version:   version,
+		Commit:    commit,
+		Date:      date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+
+	bi, ok := runtimedebug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if info.Version == "dev" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+		info.Version = bi.Main.Version
+	}
+	for _, s := range bi.Settings {
+		switch {
+		case s.Key == "vcs.revision" && info.Commit == "":
+			info.Commit = s.Value
+		case s.Key == "vcs.time" && info.Date == "":
+			info.Date = s.Value
+		}
+	}
+	return info
+}
+
+// userAgent identifies zonehero in the requests to the API
+func userAgent() string {
+	return fmt.Sprintf("zonehero/%s", currentBuildInfo().Version)
+}
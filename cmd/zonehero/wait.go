@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gitlab.guerraz.net/HLB/hlb-terraform-provider/hlb"
+)
+
+func init() {
+	// Wait Commands
+	hlbCmd.AddCommand(waitCmd)
+	waitCmd.AddCommand(waitLoadBalancerCmd)
+
+	// Wait Load Balancer Flags
+	waitLoadBalancerCmd.Flags().StringSlice("id", []string{}, "ID of the load balancer to wait for (can be repeated)")
+	waitLoadBalancerCmd.Flags().String("state", hlb.LBStateActive, "State to wait for: active or deleted")
+	waitLoadBalancerCmd.Flags().Duration("timeout", hlb.DefaultCreateTimeout, "Maximum time to wait")
+	waitLoadBalancerCmd.MarkFlagRequired("id")
+}
+
+var waitCmd = &cobra.Command{
+	Use:   "wait",
+	Short: "Wait for resources to reach a state",
+}
+
+var waitLoadBalancerCmd = &cobra.Command{
+	Use:   "load-balancer",
+	Short: "Wait for one or more load balancers to reach a state",
+	Long: `Wait for one or more load balancers to reach a state, printing every state transition.
+The command exits with a non-zero status if any load balancer enters the failed state, or
+does not reach the requested state before the timeout.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ids, _ := cmd.Flags().GetStringSlice("id")
+		state, _ := cmd.Flags().GetString("state")
+		timeout, _ := cmd.Flags().GetDuration("timeout")
+
+		if err := validateOneOf("state", state, []string{hlb.LBStateActive, hlb.LBStateDeleted}); err != nil {
+			return err
+		}
+
+		client, err := createClient(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		events := outputPrinter.events()
+
+		results := waitForLoadBalancers(cmd.Context(), client, ids, state, timeout, events)
+
+		var failures []string
+		items := make([]*hlb.LoadBalancer, 0, len(results))
+		for _, r := range results {
+			if r.err != nil {
+				failures = append(failures, r.err.Error())
+				continue
+			}
+			items = append(items, r.lb)
+		}
+
+		if err := outputPrinter.printMessage(map[string]interface{}{"items": items}, "%d of %d load balancers reached state %s", len(items), len(ids), state); err != nil {
+			return err
+		}
+
+		if len(failures) > 0 {
+			return errors.New(strings.Join(failures, "\n"))
+		}
+		return nil
+	},
+}
+
+type waitResult struct {
+	id  string
+	lb  *hlb.LoadBalancer
+	err error
+}
+
+// waitForLoadBalancers waits concurrently for every load balancer in ids to reach state,
+// writing state transitions to events as they are observed
+func waitForLoadBalancers(ctx context.Context, client *hlb.Client, ids []string, state string, timeout time.Duration, events io.Writer) []waitResult {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	results := make([]waitResult, len(ids))
+
+	for i, id := range ids {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+
+			lastState := ""
+			lb, err := client.WaitForLoadBalancerState(ctx, id, []string{state}, timeout, func(lb *hlb.LoadBalancer) {
+				if lb.State == lastState {
+					return
+				}
+				mu.Lock()
+				defer mu.Unlock()
+				if lastState == "" {
+					fmt.Fprintf(events, "%s  %s  %s\n", time.Now().Format(time.RFC3339), id, lb.State)
+				} else {
+					fmt.Fprintf(events, "%s  %s  %s -> %s\n", time.Now().Format(time.RFC3339), id, lastState, lb.State)
+				}
+				lastState = lb.State
+			})
+			results[i] = waitResult{id: id, lb: lb, err: err}
+		}(i, id)
+	}
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gitlab.guerraz.net/HLB/hlb-terraform-provider/hlb"
+)
+
+const (
+	watchEventAdded    = "added"
+	watchEventModified = "modified"
+	watchEventRemoved  = "removed"
+
+	ansiClearScreen = "\033[H\033[2J"
+	ansiHighlight   = "\033[1;33m"
+	ansiReset       = "\033[0m"
+)
+
+func init() {
+	// Watch Commands
+	hlbCmd.AddCommand(watchCmd)
+	addWatchFlags(watchCmd)
+	addSelectorFlags(watchCmd)
+
+	// List Load Balancers Watch Flags
+	listLoadBalancersCmd.Flags().Bool("watch", false, "Keep polling and redraw the list when load balancers change")
+	addWatchFlags(listLoadBalancersCmd)
+}
+
+func addWatchFlags(cmd *cobra.Command) {
+	cmd.Flags().Duration("interval", 10*time.Second, "Polling interval")
+	cmd.Flags().Bool("events", false, "Emit newline-delimited JSON change events instead of a table")
+}
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Watch the state of every load balancer",
+	Long: `Poll every load balancer at a regular interval and display a live status table.
+Rows that changed since the previous poll are highlighted. Use --events to emit
+newline-delimited JSON change events suitable for piping into other tools.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWatch(cmd)
+	},
+}
+
+// watchSnapshot holds the load balancer fields tracked by watch
+type watchSnapshot struct {
+	Name             string `json:"name"`
+	State            string `json:"state"`
+	Version          string `json:"version"`
+	InstanceType     string `json:"instanceType"`
+	MinInstanceCount int    `json:"minInstanceCount"`
+	MaxInstanceCount int    `json:"maxInstanceCount"`
+	TargetCPUUsage   int    `json:"targetCpuUsage"`
+}
+
+type watchChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+type watchEvent struct {
+	Time         time.Time              `json:"time"`
+	Type         string                 `json:"type"`
+	ID           string                 `json:"id"`
+	Name         string                 `json:"name"`
+	Changes      map[string]watchChange `json:"changes,omitempty"`
+	LoadBalancer *hlb.LoadBalancer      `json:"loadBalancer,omitempty"`
+}
+
+func newWatchSnapshot(lb *hlb.LoadBalancer) watchSnapshot {
+	s := watchSnapshot{Name: lb.Name, State: lb.State}
+	if lb.DeploymentStatus != nil {
+		s.Version = lb.DeploymentStatus.Version
+	}
+	if lb.LaunchConfig != nil {
+		s.InstanceType = lb.LaunchConfig.InstanceType
+		s.MinInstanceCount = lb.LaunchConfig.MinInstanceCount
+		s.MaxInstanceCount = lb.LaunchConfig.MaxInstanceCount
+		s.TargetCPUUsage = lb.LaunchConfig.TargetCPUUsage
+	}
+	return s
+}
+
+// diff returns the fields that changed between s and next, keyed by their JSON name
+func (s watchSnapshot) diff(next watchSnapshot) map[string]watchChange {
+	changes := map[string]watchChange{}
+	add := func(name string, from, to interface{}) {
+		if from != to {
+			changes[name] = watchChange{From: from, To: to}
+		}
+	}
+	add("name", s.Name, next.Name)
+	add("state", s.State, next.State)
+	add("version", s.Version, next.Version)
+	add("instanceType", s.InstanceType, next.InstanceType)
+	add("minInstanceCount", s.MinInstanceCount, next.MinInstanceCount)
+	add("maxInstanceCount", s.MaxInstanceCount, next.MaxInstanceCount)
+	add("targetCpuUsage", s.TargetCPUUsage, next.TargetCPUUsage)
+	return changes
+}
+
+func runWatch(cmd *cobra.Command) error {
+	interval, _ := cmd.Flags().GetDuration("interval")
+	emitEvents, _ := cmd.Flags().GetBool("events")
+	if interval <= 0 {
+		return fmt.Errorf("invalid value %s for interval: must be positive", interval)
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+	defer stop()
+
+	client, err := createClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	f, err := filterFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	highlight := isTerminal(os.Stdout)
+	previous := map[string]watchSnapshot{}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for first := true; ; first = false {
+		if !first {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+
+		loadBalancers, err := listAllLoadBalancers(ctx, client)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if isFatalWatchError(err) {
+				return err
+			}
+			// Transient failures are reported and polled again, the previous state is kept
+			fmt.Fprintf(os.Stderr, "Warning: failed to poll load balancers, retrying in %s: %v\n", interval, err)
+			continue
+		}
+		if f != nil {
+			loadBalancers = f.filter(loadBalancers)
+		}
+
+		now := time.Now()
+		current := make(map[string]watchSnapshot, len(loadBalancers))
+		var events []watchEvent
+		for i := range loadBalancers {
+			lb := &loadBalancers[i]
+			snapshot := newWatchSnapshot(lb)
+			current[lb.ID] = snapshot
+
+			before, seen := previous[lb.ID]
+			switch {
+			case !seen:
+				events = append(events, watchEvent{Time: now, Type: watchEventAdded, ID: lb.ID, Name: lb.Name, LoadBalancer: lb})
+			default:
+				if changes := before.diff(snapshot); len(changes) > 0 {
+					events = append(events, watchEvent{Time: now, Type: watchEventModified, ID: lb.ID, Name: lb.Name, Changes: changes, LoadBalancer: lb})
+				}
+			}
+		}
+		for id, before := range previous {
+			if _, ok := current[id]; !ok {
+				events = append(events, watchEvent{Time: now, Type: watchEventRemoved, ID: id, Name: before.Name})
+			}
+		}
+
+		if emitEvents {
+			enc := json.NewEncoder(os.Stdout)
+			for _, event := range events {
+				if err := enc.Encode(event); err != nil {
+					return err
+				}
+			}
+		} else {
+			changed := make(map[string]bool, len(events))
+			// Everything is new on the first poll, only highlight later transitions
+			if len(previous) > 0 {
+				for _, event := range events {
+					changed[event.ID] = true
+				}
+			}
+			renderWatchTable(os.Stdout, loadBalancers, changed, interval, now, highlight)
+		}
+
+		previous = current
+	}
+}
+
+// isFatalWatchError reports whether err cannot be fixed by polling again, such as invalid
+// credentials
+func isFatalWatchError(err error) bool {
+	switch classifyError(err).ExitCode {
+	case exitAuth, exitNotFound, exitUsage:
+		return true
+	}
+	return false
+}
+
+func renderWatchTable(out io.Writer, loadBalancers []hlb.LoadBalancer, changed map[string]bool, interval time.Duration, now time.Time, highlight bool) {
+	if highlight {
+		fmt.Fprint(out, ansiClearScreen)
+	}
+	fmt.Fprintf(out, "Every %s: zonehero hlb watch    %s\n\n", interval, now.Format(time.RFC3339))
+
+	sort.Slice(loadBalancers, func(i, j int) bool {
+		return loadBalancers[i].Name < loadBalancers[j].Name
+	})
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSTATE\tVERSION\tINSTANCE TYPE\tMIN\tMAX\tTARGET CPU")
+	for _, lb := range loadBalancers {
+		s := newWatchSnapshot(&lb)
+		fmt.Fprintln(w, strings.Join([]string{
+			lb.ID,
+			s.Name,
+			s.State,
+			orNone(s.Version),
+			orNone(s.InstanceType),
+			strconv.Itoa(s.MinInstanceCount),
+			strconv.Itoa(s.MaxInstanceCount),
+			strconv.Itoa(s.TargetCPUUsage),
+		}, "\t"))
+	}
+	w.Flush()
+
+	// Highlight rows after alignment so escape sequences don't skew column widths
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	fmt.Fprintln(out, lines[0])
+	for i, line := range lines[1:] {
+		switch {
+		case !changed[loadBalancers[i].ID]:
+			fmt.Fprintln(out, line)
+		case highlight:
+			fmt.Fprintf(out, "%s%s%s\n", ansiHighlight, line, ansiReset)
+		default:
+			fmt.Fprintf(out, "%s  *\n", line)
+		}
+	}
+	if !highlight {
+		fmt.Fprintln(out)
+	}
+}
+
+// isTerminal reports whether f is attached to a terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
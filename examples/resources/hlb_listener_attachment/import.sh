@@ -1,2 +1,2 @@
-# Listener attachment can be imported using its ID
-terraform import hlb_listener_attachment.front_end lis-1234567890abcdef
+# Listener attachment can be imported using the load balancer ID and the listener ID separated by a slash
+terraform import hlb_listener_attachment.front_end lb-1234567890abcdef/lis-1234567890abcdef
@@ -0,0 +1,273 @@
+package hlb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	// CassetteAccountID replaces the AWS account ID in recorded cassettes. Clients replaying a
+	// cassette use it as their account ID.
+	CassetteAccountID = "000000000000"
+
+	// RecordEnvVar and ReplayEnvVar name the cassette read by WithCassetteFromEnv
+	RecordEnvVar = "HLB_RECORD"
+	ReplayEnvVar = "HLB_REPLAY"
+)
+
+// arnAccountID matches the account ID of ARNs, such as the ARNs of target groups that can belong
+// to another account than the one of the client
+var arnAccountID = regexp.MustCompile(`\b(arn:aws[a-z-]*:[a-z0-9-]*:[a-z0-9-]*:)[0-9]{12}:`)
+
+// cassette holds the HTTP interactions of a client with the API, in the order they happened
+type cassette struct {
+	Interactions []interaction `json:"interactions"`
+}
+
+type interaction struct {
+	Request  recordedRequest  `json:"request"`
+	Response recordedResponse `json:"response"`
+}
+
+type recordedRequest struct {
+	Method string `json:"method"`
+	// URL is the path and query of the request, without the API endpoint
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+}
+
+type recordedResponse struct {
+	StatusCode int               `json:"statusCode"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       string            `json:"body,omitempty"`
+}
+
+// WithRecording records the requests sent to the API and their responses to the cassette file at
+// path. Credentials are removed, and the AWS account ID of the client and the account IDs of ARNs
+// are replaced with CassetteAccountID.
+// Interactions are appended to the cassette if it exists, delete it to record again.
+func WithRecording(path string) ClientOption {
+	return func(c *Client) {
+		c.recordPath = path
+	}
+}
+
+// WithReplay serves the responses recorded in the cassette file at path instead of sending
+// requests to the API. Each recorded interaction is served once, in the order it was recorded,
+// to the first request with the same method, URL and body once sanitized like recorded ones. No
+// AWS credentials are needed.
+func WithReplay(path string) ClientOption {
+	return func(c *Client) {
+		c.replayPath = path
+	}
+}
+
+// WithCassetteFromEnv records to the cassette named by HLB_RECORD, or replays the cassette named
+// by HLB_REPLAY
+func WithCassetteFromEnv() ClientOption {
+	return func(c *Client) {
+		if path := os.Getenv(ReplayEnvVar); path != "" {
+			c.replayPath = path
+		} else if path := os.Getenv(RecordEnvVar); path != "" {
+			c.recordPath = path
+		}
+	}
+}
+
+func loadCassette(path string) (*cassette, error) {
+	var cas cassette
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &cas); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	return &cas, nil
+}
+
+// startRecording sends the requests of the client through a transport recording them
+func (c *Client) startRecording() error {
+	cas, err := loadCassette(c.recordPath)
+	if errors.Is(err, os.ErrNotExist) {
+		cas, err = &cassette{}, nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load cassette: %w", err)
+	}
+
+	c.httpClient.HTTPClient.Transport = &recordingTransport{
+		client:   c,
+		next:     transportOrDefault(c.httpClient.HTTPClient.Transport),
+		path:     c.recordPath,
+		cassette: cas,
+	}
+	return nil
+}
+
+// startReplay serves the requests of the client from the cassette
+func (c *Client) startReplay() error {
+	cas, err := loadCassette(c.replayPath)
+	if err != nil {
+		return fmt.Errorf("failed to load cassette: %w", err)
+	}
+
+	c.httpClient.HTTPClient.Transport = &replayTransport{
+		apiKey:   c.apiKey,
+		path:     c.replayPath,
+		cassette: cas,
+		used:     make([]bool, len(cas.Interactions)),
+	}
+	c.accountID = CassetteAccountID
+	c.credentials = &Credentials{APIKey: c.apiKey, AccountID: CassetteAccountID}
+	return nil
+}
+
+type recordingTransport struct {
+	client *Client
+	next   http.RoundTripper
+	path   string
+
+	mu       sync.Mutex
+	cassette *cassette
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil {
+		var err error
+		if reqBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	recorded := interaction{
+		Request: recordedRequest{
+			Method:  req.Method,
+			URL:     t.sanitize(req.URL.RequestURI()),
+			Headers: t.sanitizeHeaders(req.Header),
+			Body:    t.sanitize(string(reqBody)),
+		},
+		Response: recordedResponse{
+			StatusCode: resp.StatusCode,
+			Headers:    t.sanitizeHeaders(resp.Header),
+			Body:       t.sanitize(string(respBody)),
+		},
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cassette.Interactions = append(t.cassette.Interactions, recorded)
+	// The cassette is saved after every interaction, processes such as the provider are killed
+	// rather than closed
+	if err := t.save(); err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to save cassette: %w", err)
+	}
+	return resp, nil
+}
+
+func (t *recordingTransport) save() error {
+	data, err := json.MarshalIndent(t.cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(t.path, data, 0600)
+}
+
+func (t *recordingTransport) sanitize(s string) string {
+	return sanitizeCassette(s, t.client.apiKey, t.client.accountID)
+}
+
+// sanitizeCassette replaces apiKey, accountID and the account IDs of ARNs in s
+func sanitizeCassette(s, apiKey, accountID string) string {
+	if apiKey != "" {
+		s = strings.ReplaceAll(s, apiKey, "<redacted>")
+	}
+	if accountID != "" {
+		s = strings.ReplaceAll(s, accountID, CassetteAccountID)
+	}
+	return arnAccountID.ReplaceAllString(s, "${1}"+CassetteAccountID+":")
+}
+
+func (t *recordingTransport) sanitizeHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for k := range header {
+		if isRedactedHeader(k) {
+			headers[k] = "<redacted>"
+		} else {
+			headers[k] = t.sanitize(header.Get(k))
+		}
+	}
+	return headers
+}
+
+type replayTransport struct {
+	apiKey string
+	path   string
+
+	mu       sync.Mutex
+	cassette *cassette
+	used     []bool
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+	// The account ID of replaying clients already is CassetteAccountID
+	sanitized := sanitizeCassette(string(body), t.apiKey, "")
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, recorded := range t.cassette.Interactions {
+		if t.used[i] || recorded.Request.Method != req.Method || recorded.Request.URL != req.URL.RequestURI() || recorded.Request.Body != sanitized {
+			continue
+		}
+		t.used[i] = true
+
+		header := http.Header{}
+		for k, v := range recorded.Response.Headers {
+			header.Set(k, v)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", recorded.Response.StatusCode, http.StatusText(recorded.Response.StatusCode)),
+			StatusCode:    recorded.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(strings.NewReader(recorded.Response.Body)),
+			ContentLength: int64(len(recorded.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("no interaction left in cassette %s for %s %s with the same body", t.path, req.Method, req.URL.RequestURI())
+}
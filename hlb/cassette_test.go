@@ -0,0 +1,119 @@
+package hlb
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestSanitizeCassette(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "api key",
+			input: `{"x-api-key":"secret-key"}`,
+			want:  `{"x-api-key":"<redacted>"}`,
+		},
+		{
+			name:  "client account",
+			input: "/aws_account/123456789012/load-balancers",
+			want:  "/aws_account/000000000000/load-balancers",
+		},
+		{
+			name:  "target group of another account",
+			input: `{"targetGroupArn":"arn:aws:elasticloadbalancing:us-east-1:210987654321:targetgroup/web/0123456789abcdef"}`,
+			want:  `{"targetGroupArn":"arn:aws:elasticloadbalancing:us-east-1:000000000000:targetgroup/web/0123456789abcdef"}`,
+		},
+		{
+			name:  "global service in another partition",
+			input: "arn:aws-us-gov:iam::210987654321:role/hlb/hlb-admin-users-role",
+			want:  "arn:aws-us-gov:iam::000000000000:role/hlb/hlb-admin-users-role",
+		},
+		{
+			name:  "several arns",
+			input: "arn:aws:s3:::bucket arn:aws:iam::111111111111:role/a,arn:aws:iam::222222222222:role/b",
+			want:  "arn:aws:s3:::bucket arn:aws:iam::000000000000:role/a,arn:aws:iam::000000000000:role/b",
+		},
+		{
+			name:  "numbers outside arns",
+			input: `{"idleTimeout":210987654321}`,
+			want:  `{"idleTimeout":210987654321}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sanitizeCassette(tt.input, "secret-key", "123456789012"); got != tt.want {
+				t.Errorf("sanitizeCassette() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayMatchesBody(t *testing.T) {
+	const path = "/aws_account/000000000000/load-balancers/lb-1/listeners"
+	cas := cassette{Interactions: []interaction{
+		{
+			Request:  recordedRequest{Method: http.MethodPost, URL: "/v1" + path, Body: `{"port":443,"targetGroupArn":"arn:aws:elasticloadbalancing:us-east-1:000000000000:targetgroup/b/1"}`},
+			Response: recordedResponse{StatusCode: http.StatusOK, Body: `{"id":"lis-443"}`},
+		},
+		{
+			Request:  recordedRequest{Method: http.MethodPost, URL: "/v1" + path, Body: `{"port":80,"targetGroupArn":"arn:aws:elasticloadbalancing:us-east-1:000000000000:targetgroup/a/1"}`},
+			Response: recordedResponse{StatusCode: http.StatusOK, Body: `{"id":"lis-80"}`},
+		},
+	}}
+	data, err := json.Marshal(cas)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cassettePath := filepath.Join(t.TempDir(), "cassette.json")
+	if err := os.WriteFile(cassettePath, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := NewClient(context.Background(), "test-api-key", aws.Config{Region: "us-east-1"}, "", WithReplay(cassettePath), WithEndpoint("https://hlb.example.com/v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		body    map[string]interface{}
+		wantID  string
+		wantErr bool
+	}{
+		{name: "second interaction", body: map[string]interface{}{"port": 80, "targetGroupArn": "arn:aws:elasticloadbalancing:us-east-1:210987654321:targetgroup/a/1"}, wantID: "lis-80"},
+		{name: "first interaction", body: map[string]interface{}{"port": 443, "targetGroupArn": "arn:aws:elasticloadbalancing:us-east-1:210987654321:targetgroup/b/1"}, wantID: "lis-443"},
+		{name: "interaction already used", body: map[string]interface{}{"port": 80, "targetGroupArn": "arn:aws:elasticloadbalancing:us-east-1:210987654321:targetgroup/a/1"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.sendRequest(context.Background(), http.MethodPost, path, tt.body)
+			if tt.wantErr {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatal("sendRequest() succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("sendRequest() error = %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if want := `{"id":"` + tt.wantID + `"}`; string(body) != want {
+				t.Errorf("sendRequest() body = %s, want %s", body, want)
+			}
+		})
+	}
+}
@@ -8,9 +8,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
-	"os"
+	"net/url"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -22,60 +23,128 @@ const (
 	defaultBaseHostname = "hlb.%s.%s.zonehero.cloud"
 	defaultBaseURL      = "https://%s/v1"
 	defaultPartition    = "aws"
+
+	// requestIDHeader is the response header holding the ID of the request
+	requestIDHeader = "X-Amzn-Requestid"
 )
 
 type Client struct {
-	httpClient  *retryablehttp.Client
-	baseURL     string
-	hostname    string
-	apiKey      string
-	partition   string
-	awsConfig   aws.Config
-	accountID   string
-	credentials *Credentials
-	debug       bool
-}
-
-func NewClient(ctx context.Context, apiKey string, awsConfig aws.Config, partition string) (*Client, error) {
+	httpClient    *retryablehttp.Client
+	baseURL       string
+	hostname      string
+	apiKey        string
+	partition     string
+	awsConfig     aws.Config
+	accountID     string
+	credentials   *Credentials
+	refreshMargin time.Duration
+	// logger receives the requests and responses when set, see WithLogger
+	logger Logger
+	// dryRun receives the mutating requests instead of the API when set, see WithDryRun
+	dryRun io.Writer
+	// recordPath and replayPath are the cassettes set with WithRecording and WithReplay
+	recordPath string
+	replayPath string
+	// transport, proxy, middleware and headers are set with the options of transport.go
+	transport  http.RoundTripper
+	proxy      *url.URL
+	middleware []Middleware
+	headers    http.Header
+	// userAgent identifies the application using the client, see WithUserAgent
+	userAgent string
+}
+
+// ClientOption configures optional behaviour of a Client created with NewClient.
+type ClientOption func(*Client)
+
+// WithCredentialsRefreshMargin sets how long before their expiry the STS headers sent to the
+// API are regenerated. Defaults to DefaultCredentialsRefreshMargin.
+func WithCredentialsRefreshMargin(margin time.Duration) ClientOption {
+	return func(c *Client) {
+		c.refreshMargin = margin
+	}
+}
+
+// WithEndpoint sends requests to the API at the given base URL, such as https://hlb.example.com/v1,
+// instead of the endpoint of the region and partition
+func WithEndpoint(endpoint string) ClientOption {
+	return func(c *Client) {
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Host == "" {
+			return
+		}
+		c.baseURL = strings.TrimSuffix(endpoint, "/")
+		c.hostname = u.Host
+	}
+}
+
+func NewClient(ctx context.Context, apiKey string, awsConfig aws.Config, partition string, opts ...ClientOption) (*Client, error) {
 	retryClient := retryablehttp.NewClient()
 	retryClient.RetryMax = defaultMaxRetries
 	retryClient.RetryWaitMin = 1 * time.Second
 	retryClient.RetryWaitMax = 30 * time.Second
 	retryClient.CheckRetry = customRetryPolicy
+	// Return the last response once retries are exhausted so that it is reported as an API error
+	retryClient.ErrorHandler = retryablehttp.PassthroughErrorHandler
 
-	// Disable default debug logging
+	// Requests are logged through the Logger of the client instead
 	retryClient.Logger = nil
 
+	if partition == "" {
+		partition = defaultPartition
+	}
+
 	hostname := fmt.Sprintf(defaultBaseHostname, awsConfig.Region, partition)
-	credentials, err := loadOrCreateCredentials(ctx, apiKey, awsConfig, hostname)
-	if err != nil {
+	client := &Client{
+		httpClient:    retryClient,
+		baseURL:       fmt.Sprintf(defaultBaseURL, hostname),
+		hostname:      hostname,
+		apiKey:        apiKey,
+		awsConfig:     awsConfig,
+		partition:     partition,
+		refreshMargin: DefaultCredentialsRefreshMargin,
+	}
+	retryClient.RequestLogHook = func(_ retryablehttp.Logger, req *http.Request, attempt int) {
+		if attempt > 0 {
+			countRetry(req.Context(), attempt)
+			client.log(req.Context(), LogLevelDebug, "Retrying request", map[string]interface{}{
+				"method":  req.Method,
+				"url":     req.URL.String(),
+				"attempt": attempt,
+			})
+		}
+	}
+	for _, opt := range opts {
+		opt(client)
+	}
+	if err := client.configureTransport(); err != nil {
 		return nil, err
 	}
 
-	if partition == "" {
-		partition = defaultPartition
+	// Replayed requests are not signed, no AWS credentials are needed
+	if client.replayPath != "" {
+		if err := client.startReplay(); err != nil {
+			return nil, err
+		}
+		client.applyMiddleware()
+		return client, nil
 	}
 
-	return &Client{
-		httpClient:  retryClient,
-		baseURL:     fmt.Sprintf(defaultBaseURL, hostname),
-		hostname:    hostname,
-		apiKey:      apiKey,
-		awsConfig:   awsConfig,
-		accountID:   credentials.AccountID,
-		credentials: credentials,
-		partition:   partition,
-		debug:       false,
-	}, nil
-}
+	credentials, err := loadOrCreateCredentials(ctx, apiKey, awsConfig, partition, client.hostname)
+	if err != nil {
+		return nil, &CredentialsError{Err: err}
+	}
+	client.credentials = credentials
+	client.accountID = credentials.AccountID
 
-func (c *Client) SetDebug(enabled bool) {
-	c.debug = enabled
-	if enabled {
-		c.httpClient.Logger = log.New(os.Stdout, "", log.LstdFlags)
-	} else {
-		c.httpClient.Logger = nil
+	if client.recordPath != "" {
+		if err := client.startRecording(); err != nil {
+			return nil, err
+		}
 	}
+	client.applyMiddleware()
+
+	return client, nil
 }
 
 func (c *Client) GetRegion() string {
@@ -86,42 +155,92 @@ func (c *Client) GetAccountID() string {
 	return c.accountID
 }
 
-func (c *Client) sendRequest(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
-	url := fmt.Sprintf("%s%s", c.baseURL, path)
+func (c *Client) GetPartition() string {
+	return c.partition
+}
+
+// GetHostname returns the hostname of the HLB API targeted by the client
+func (c *Client) GetHostname() string {
+	return c.hostname
+}
+
+// GetRoleARN returns the ARN of the HLB role assumed to sign API requests
+func (c *Client) GetRoleARN() string {
+	return hlbRoleARN(c.accountID)
+}
+
+// GetCredentialsExpiry returns the time at which the cached STS headers expire
+func (c *Client) GetCredentialsExpiry() time.Time {
+	c.credentials.mu.Lock()
+	defer c.credentials.mu.Unlock()
+	return c.credentials.Expiry
+}
 
-	if c.debug {
-		log.Printf("[DEBUG] %s %s", method, url)
+// RefreshCredentials regenerates the STS headers sent to the API and updates the credentials cache
+func (c *Client) RefreshCredentials(ctx context.Context) error {
+	if c.replayPath != "" {
+		return nil
 	}
 
-	XSTSGCIHeaders, err := getSCDIHeader(ctx, c.awsConfig, c.credentials, c.hostname)
-	if err != nil {
-		return nil, fmt.Errorf("failed to generate API credentials: %w", err)
+	c.credentials.mu.Lock()
+	c.credentials.Expiry = time.Time{}
+	c.credentials.mu.Unlock()
+
+	_, err := getSCDIHeader(ctx, c.awsConfig, c.credentials, c.hostname, c.refreshMargin)
+	return err
+}
+
+func (c *Client) sendRequest(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	return c.sendRequestWithHeader(ctx, method, path, body, nil)
+}
+
+// sendRequestWithHeader sends a request with additional headers. Requests with an idempotency key
+// are retried when their outcome is unknown.
+func (c *Client) sendRequestWithHeader(ctx context.Context, method, path string, body interface{}, header http.Header) (resp *http.Response, err error) {
+	if header.Get(idempotencyKeyHeader) != "" {
+		ctx = withIdempotentRequest(ctx)
 	}
+	ctx, span, stats := c.startRequestSpan(ctx, method, path)
+	start := time.Now()
+	defer func() {
+		endRequestSpan(ctx, span, stats, method, path, start, resp, err)
+	}()
 
-	var buf io.Reader
+	url := fmt.Sprintf("%s%s", c.baseURL, path)
+
+	var payloadBytes []byte
 	if body != nil {
-		payloadBytes, err := json.Marshal(body)
+		payloadBytes, err = json.Marshal(body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal request body: %w", err)
 		}
-		buf = bytes.NewBuffer(payloadBytes)
-		if c.debug {
-			log.Printf("[DEBUG] Request body: %s", string(payloadBytes))
-		}
 	}
 
-	req, err := retryablehttp.NewRequest(method, url, buf)
+	if c.dryRun != nil && method != http.MethodGet {
+		return c.dryRunRequest(ctx, method, path, payloadBytes, header)
+	}
+
+	resp, headers, err := c.doRequest(ctx, method, url, payloadBytes, header)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", c.apiKey)
-	req.Header.Set("X-Sts-Gci-Headers", XSTSGCIHeaders)
+	if isStaleCredentialsResponse(resp) {
+		// The API rejected our STS headers, most likely because they expired in flight.
+		// Regenerate them and retry once.
+		c.log(ctx, LogLevelDebug, "Request rejected, regenerating API credentials", map[string]interface{}{
+			"status":     resp.StatusCode,
+			"request_id": resp.Header.Get(requestIDHeader),
+		})
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		c.credentials.invalidate(headers)
+		countRetry(ctx, 1)
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		resp, _, err = c.doRequest(ctx, method, url, payloadBytes, header)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	if resp.StatusCode >= 400 {
@@ -130,25 +249,39 @@ func (c *Client) sendRequest(ctx context.Context, method, path string, body inte
 		resp.Body.Close() // Close the body since we won't use it anymore
 
 		if err == nil && len(bodyBytes) > 0 {
-			if c.debug {
-				log.Printf("[DEBUG] Error response body: %s", string(bodyBytes))
-			}
+			c.log(ctx, LogLevelDebug, "Error response body", map[string]interface{}{
+				"request_id": resp.Header.Get(requestIDHeader),
+				"body":       string(bodyBytes),
+			})
 
 			var apiErr APIErrorResponse
 			if json.Unmarshal(bodyBytes, &apiErr) == nil {
-				return nil, &apiErr
+				if apiErr.Code == 0 {
+					apiErr.Code = resp.StatusCode
+				}
+				apiErr.StatusCode = resp.StatusCode
+				apiErr.RequestID = resp.Header.Get(requestIDHeader)
+				return nil, conditionalError(header, &apiErr)
 			}
 		}
 
 		// Fallback if we couldn't parse the error response
-		return nil, fmt.Errorf("API request failed with HTTP status %d", resp.StatusCode)
+		return nil, conditionalError(header, &APIErrorResponse{
+			Code:       resp.StatusCode,
+			Message:    http.StatusText(resp.StatusCode),
+			StatusCode: resp.StatusCode,
+			RequestID:  resp.Header.Get(requestIDHeader),
+		})
 	}
 
-	if c.debug && resp.Body != nil {
-		// Read the response body for debug logging
+	if c.logger != nil && resp.Body != nil {
+		// Read the response body for logging
 		bodyBytes, err := io.ReadAll(resp.Body)
 		if err == nil {
-			log.Printf("[DEBUG] Response body: %s", string(bodyBytes))
+			c.log(ctx, LogLevelTrace, "Response body", map[string]interface{}{
+				"request_id": resp.Header.Get(requestIDHeader),
+				"body":       string(bodyBytes),
+			})
 			// Create a new reader with the same bytes for the actual response
 			resp.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
 		}
@@ -157,15 +290,96 @@ func (c *Client) sendRequest(ctx context.Context, method, path string, body inte
 	return resp, nil
 }
 
+// doRequest sends a single authenticated request and returns the response together with the
+// STS headers it was signed with.
+func (c *Client) doRequest(ctx context.Context, method, url string, payload []byte, header http.Header) (*http.Response, string, error) {
+	var XSTSGCIHeaders string
+	if c.replayPath == "" {
+		var err error
+		XSTSGCIHeaders, err = getSCDIHeader(ctx, c.awsConfig, c.credentials, c.hostname, c.refreshMargin)
+		if err != nil {
+			return nil, "", &CredentialsError{Err: fmt.Errorf("failed to generate API credentials: %w", err)}
+		}
+	}
+
+	var buf io.Reader
+	if payload != nil {
+		buf = bytes.NewReader(payload)
+	}
+
+	req, err := retryablehttp.NewRequestWithContext(ctx, method, url, buf)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", c.UserAgent())
+	// The values are copied so that changes to the request headers do not leak to the client
+	for k, v := range c.headers {
+		req.Header[k] = slices.Clone(v)
+	}
+	for k, v := range header {
+		req.Header[k] = slices.Clone(v)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", c.apiKey)
+	req.Header.Set("X-Sts-Gci-Headers", XSTSGCIHeaders)
+
+	c.log(ctx, LogLevelDebug, "Sending request", map[string]interface{}{
+		"method": method,
+		"url":    url,
+	})
+	if c.logger != nil {
+		fields := map[string]interface{}{"headers": logHeaders(req.Header)}
+		if payload != nil {
+			fields["body"] = string(payload)
+		}
+		c.log(ctx, LogLevelTrace, "Request", fields)
+	}
+
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.log(ctx, LogLevelDebug, "Request failed", map[string]interface{}{
+			"method":      method,
+			"url":         url,
+			"duration_ms": time.Since(start).Milliseconds(),
+			"error":       err.Error(),
+		})
+		return nil, "", fmt.Errorf("failed to send request: %w", err)
+	}
+	c.log(ctx, LogLevelDebug, "Received response", map[string]interface{}{
+		"method":      method,
+		"url":         url,
+		"status":      resp.StatusCode,
+		"duration_ms": time.Since(start).Milliseconds(),
+		"request_id":  resp.Header.Get(requestIDHeader),
+	})
+
+	return resp, XSTSGCIHeaders, nil
+}
+
+// isStaleCredentialsResponse reports whether the API rejected the request's STS headers
+func isStaleCredentialsResponse(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden
+}
+
 func customRetryPolicy(ctx context.Context, resp *http.Response, err error) (bool, error) {
+	// Requests with an idempotency key are sent again when the API may not have processed them
+	idempotent := isIdempotentRequest(ctx) && ctx.Err() == nil
+
 	if err != nil {
+		if idempotent {
+			return true, nil
+		}
 		return false, err
 	}
 
-	// Retry only if the response status code is 429
 	if resp.StatusCode == http.StatusTooManyRequests {
 		return true, nil
 	}
+	if idempotent && (resp.StatusCode == http.StatusBadGateway || resp.StatusCode == http.StatusServiceUnavailable || resp.StatusCode == http.StatusGatewayTimeout) {
+		return true, nil
+	}
 
 	return false, nil
 }
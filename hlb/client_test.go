@@ -0,0 +1,262 @@
+package hlb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// newTestClient returns a client whose requests are answered by handler instead of the API
+func newTestClient(t *testing.T, handler func(req *http.Request) *http.Response, opts ...ClientOption) *Client {
+	t.Helper()
+
+	// Replayed clients need no AWS credentials, handler answers before the empty cassette is used
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	if err := os.WriteFile(path, []byte(`{"interactions":[]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	opts = append([]ClientOption{WithReplay(path), WithMiddleware(func(http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Body != nil {
+				io.Copy(io.Discard, req.Body)
+				req.Body.Close()
+			}
+			return handler(req), nil
+		})
+	})}, opts...)
+
+	client, err := NewClient(context.Background(), "test-api-key", aws.Config{Region: "us-east-1"}, "", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// testResponse returns a response with status and body
+func testResponse(req *http.Request, status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+		Request:    req,
+	}
+}
+
+func TestSendRequestStaleCredentials(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantRequests int
+		wantStatus   int // status of the APIErrorResponse returned, 0 for success
+	}{
+		{name: "success", statuses: []int{http.StatusOK}, wantRequests: 1},
+		{name: "unauthorized once", statuses: []int{http.StatusUnauthorized, http.StatusOK}, wantRequests: 2},
+		{name: "forbidden once", statuses: []int{http.StatusForbidden, http.StatusOK}, wantRequests: 2},
+		{name: "retried once only", statuses: []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusOK}, wantRequests: 2, wantStatus: http.StatusForbidden},
+		{name: "not found", statuses: []int{http.StatusNotFound, http.StatusOK}, wantRequests: 1, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			client := newTestClient(t, func(req *http.Request) *http.Response {
+				status := tt.statuses[requests]
+				requests++
+				if status != http.StatusOK {
+					return testResponse(req, status, `{"message":"rejected"}`)
+				}
+				return testResponse(req, status, `{"id":"lb-1"}`)
+			})
+
+			_, err := client.GetLoadBalancer(context.Background(), "lb-1")
+			if requests != tt.wantRequests {
+				t.Errorf("sent %d requests, want %d", requests, tt.wantRequests)
+			}
+
+			var apiErr *APIErrorResponse
+			switch {
+			case tt.wantStatus == 0 && err != nil:
+				t.Errorf("GetLoadBalancer() error = %v, want nil", err)
+			case tt.wantStatus != 0 && !errors.As(err, &apiErr):
+				t.Errorf("GetLoadBalancer() error = %v, want an APIErrorResponse", err)
+			case tt.wantStatus != 0 && apiErr.StatusCode != tt.wantStatus:
+				t.Errorf("GetLoadBalancer() status = %d, want %d", apiErr.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestWithHeaders(t *testing.T) {
+	var seen []string
+	client := newTestClient(t, func(req *http.Request) *http.Response {
+		seen = append(seen, req.Header.Get("X-Team"))
+		// Transports may change the request headers, the next requests must not see it
+		req.Header["X-Team"][0] = "changed"
+		return testResponse(req, http.StatusOK, `{}`)
+	}, WithHeaders(http.Header{"X-Team": {"edge"}, "Authorization": {"Bearer token"}}))
+
+	for range 2 {
+		resp, err := client.sendRequest(context.Background(), http.MethodGet, "/load-balancers", nil)
+		if err != nil {
+			t.Fatalf("sendRequest() error = %v", err)
+		}
+		resp.Body.Close()
+	}
+	if want := []string{"edge", "edge"}; strings.Join(seen, ",") != strings.Join(want, ",") {
+		t.Errorf("X-Team headers sent = %v, want %v", seen, want)
+	}
+
+	var out strings.Builder
+	client.dryRun = &out
+	resp, err := client.sendRequest(context.Background(), http.MethodDelete, "/load-balancers/lb-1", nil)
+	if err != nil {
+		t.Fatalf("sendRequest() error = %v", err)
+	}
+	resp.Body.Close()
+	if !strings.Contains(out.String(), "[dry-run] Authorization: <redacted>\n") || strings.Contains(out.String(), "Bearer token") {
+		t.Errorf("dry-run output does not redact the Authorization header:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "[dry-run] X-Team: edge\n") {
+		t.Errorf("dry-run output is missing the X-Team header:\n%s", out.String())
+	}
+}
+
+func TestLogHeaders(t *testing.T) {
+	header := http.Header{
+		"X-Api-Key":           {"abcdefghijkl"},
+		"X-Sts-Gci-Headers":   {"signed"},
+		"Authorization":       {"Bearer token"},
+		"Proxy-Authorization": {"Basic dXNlcjpwYXNz"},
+		"Cookie":              {"session=1"},
+		"X-Team":              {"edge"},
+	}
+	want := map[string]string{
+		"X-Api-Key":           "abcd********",
+		"X-Sts-Gci-Headers":   "<redacted>",
+		"Authorization":       "<redacted>",
+		"Proxy-Authorization": "<redacted>",
+		"Cookie":              "<redacted>",
+		"X-Team":              "edge",
+	}
+
+	got := logHeaders(header)
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("logHeaders()[%q] = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestCreateRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		clientToken  bool
+		wantRequests int
+		wantUnknown  bool
+	}{
+		{name: "gateway error with a client token", statuses: []int{http.StatusServiceUnavailable, http.StatusOK}, clientToken: true, wantRequests: 2},
+		{name: "gateway errors until the last retry", statuses: []int{502, 503, 504, 502, 503, 504}, clientToken: true, wantRequests: 6, wantUnknown: true},
+		{name: "gateway error without a client token", statuses: []int{http.StatusServiceUnavailable}, wantRequests: 1, wantUnknown: true},
+		{name: "server error", statuses: []int{http.StatusInternalServerError}, clientToken: true, wantRequests: 1},
+		{name: "throttled", statuses: []int{http.StatusTooManyRequests, http.StatusOK}, wantRequests: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var tokens []string
+			client := newTestClient(t, func(req *http.Request) *http.Response {
+				tokens = append(tokens, req.Header.Get(idempotencyKeyHeader))
+				status := tt.statuses[len(tokens)-1]
+				if status != http.StatusOK {
+					return testResponse(req, status, `{"message":"unavailable"}`)
+				}
+				return testResponse(req, status, `{"id":"lb-1"}`)
+			})
+			client.httpClient.RetryWaitMin, client.httpClient.RetryWaitMax = 0, 0
+
+			var header http.Header
+			if tt.clientToken {
+				header = idempotencyHeader("")
+			}
+			resp, err := client.sendRequestWithHeader(context.Background(), http.MethodPost, "/load-balancers", struct{}{}, header)
+			if err == nil {
+				resp.Body.Close()
+			}
+
+			if len(tokens) != tt.wantRequests {
+				t.Errorf("sent %d requests, want %d", len(tokens), tt.wantRequests)
+			}
+			for _, token := range tokens {
+				if token != tokens[0] {
+					t.Errorf("client tokens = %v, want the same token for every attempt", tokens)
+					break
+				}
+			}
+			if got := IsOutcomeUnknown(err); got != tt.wantUnknown {
+				t.Errorf("IsOutcomeUnknown(%v) = %t, want %t", err, got, tt.wantUnknown)
+			}
+		})
+	}
+}
+
+func TestCreateClientToken(t *testing.T) {
+	var tokens []string
+	client := newTestClient(t, func(req *http.Request) *http.Response {
+		tokens = append(tokens, req.Header.Get(idempotencyKeyHeader))
+		return testResponse(req, http.StatusOK, `{"id":"lis-1"}`)
+	})
+
+	// An input reused for several listeners sends a new token for each of them
+	input := &ListenerCreate{Port: 80, Protocol: "HTTP"}
+	for range 2 {
+		if _, err := client.CreateListener(context.Background(), "lb-1", input); err != nil {
+			t.Fatalf("CreateListener() error = %v", err)
+		}
+	}
+	if input.ClientToken != "" {
+		t.Errorf("CreateListener() set ClientToken to %q, want the input unchanged", input.ClientToken)
+	}
+	if tokens[0] == "" || tokens[0] == tokens[1] {
+		t.Errorf("client tokens = %q, want a different token for each creation", tokens)
+	}
+
+	// A token set by the caller is sent again to resume the creation
+	input.ClientToken = "resume"
+	if _, err := client.CreateListener(context.Background(), "lb-1", input); err != nil {
+		t.Fatalf("CreateListener() error = %v", err)
+	}
+	if tokens[2] != "resume" {
+		t.Errorf("client token = %q, want %q", tokens[2], "resume")
+	}
+}
+
+func TestCreateLoadBalancerIncomplete(t *testing.T) {
+	client := newTestClient(t, func(req *http.Request) *http.Response {
+		if req.Method == http.MethodPost {
+			return testResponse(req, http.StatusOK, `{"id":"lb-1","state":"provisioning"}`)
+		}
+		return testResponse(req, http.StatusOK, `{"id":"lb-1","state":"failed","deploymentStatus":{"errorMessage":"no capacity"}}`)
+	})
+
+	_, err := client.CreateLoadBalancer(context.Background(), &LoadBalancerCreate{Name: "web"})
+
+	var incompleteErr *IncompleteCreateError
+	if !errors.As(err, &incompleteErr) || incompleteErr.ID != "lb-1" {
+		t.Fatalf("CreateLoadBalancer() error = %v, want an IncompleteCreateError for lb-1", err)
+	}
+	var failedErr *LoadBalancerFailedError
+	if !errors.As(err, &failedErr) {
+		t.Errorf("CreateLoadBalancer() error = %v, want it to wrap the LoadBalancerFailedError", err)
+	}
+	if IsOutcomeUnknown(err) {
+		t.Errorf("IsOutcomeUnknown(%v) = true, want false since the load balancer is known", err)
+	}
+}
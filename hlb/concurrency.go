@@ -0,0 +1,97 @@
+package hlb
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	etagHeader    = "ETag"
+	ifMatchHeader = "If-Match"
+
+	// versionPrefix marks the versions ETag derives from a resource when the API sent no ETag
+	// header. They are never sent as If-Match, updates compare them with the version read again
+	// just before the request instead.
+	versionPrefix = "version:"
+)
+
+// ETag identifies the version of the load balancer. Pass it as LoadBalancerUpdate.IfMatch to only
+// update this version. It is the ETag header of the response the load balancer was read from, or
+// when the API sent none, a version derived from its deployment version and update time.
+func (lb *LoadBalancer) ETag() string {
+	if lb.etag != "" {
+		return lb.etag
+	}
+	return lb.version()
+}
+
+// version returns the version of the load balancer derived by the client
+func (lb *LoadBalancer) version() string {
+	deploymentVersion := ""
+	if lb.DeploymentStatus != nil {
+		deploymentVersion = lb.DeploymentStatus.Version
+	}
+	return resourceVersion(deploymentVersion, lb.UpdatedAt)
+}
+
+// ETag identifies the version of the listener. Pass it as ListenerUpdate.IfMatch to only update
+// this version. It is the ETag header of the response the listener was read from, or when the API
+// sent none, a version derived from its update time.
+func (l *Listener) ETag() string {
+	if l.etag != "" {
+		return l.etag
+	}
+	return l.version()
+}
+
+// version returns the version of the listener derived by the client
+func (l *Listener) version() string {
+	return resourceVersion("", l.UpdatedAt)
+}
+
+// resourceVersion returns the version of a resource read without an ETag header, empty when it
+// has neither a deployment version nor an update time
+func resourceVersion(deploymentVersion string, updatedAt time.Time) string {
+	if deploymentVersion == "" && updatedAt.IsZero() {
+		return ""
+	}
+	return versionPrefix + deploymentVersion + "@" + updatedAt.UTC().Format(time.RFC3339Nano)
+}
+
+// precondition returns the header making an update conditional on etag. ETags issued by the API
+// are sent as If-Match. Versions derived by the client are compared with the version returned by
+// current, read just before the update, and a ConflictError is returned when they differ. This
+// narrows the window for lost updates but unlike If-Match does not close it.
+func precondition(ctx context.Context, etag string, current func(context.Context) (string, error)) (http.Header, error) {
+	if !strings.HasPrefix(etag, versionPrefix) {
+		return ifMatch(etag), nil
+	}
+	version, err := current(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if version != etag {
+		return nil, &ConflictError{ETag: etag, Current: version}
+	}
+	return nil, nil
+}
+
+// ifMatch returns the header making an update conditional on etag, nil when etag is empty
+func ifMatch(etag string) http.Header {
+	if etag == "" {
+		return nil
+	}
+	return http.Header{ifMatchHeader: []string{etag}}
+}
+
+// conditionalError returns the error of a response to a request sent with header, a
+// ConflictError when the If-Match condition of the request failed
+func conditionalError(header http.Header, apiErr *APIErrorResponse) error {
+	etag := header.Get(ifMatchHeader)
+	if etag != "" && apiErr.StatusCode == http.StatusPreconditionFailed {
+		return &ConflictError{ETag: etag, Err: apiErr}
+	}
+	return apiErr
+}
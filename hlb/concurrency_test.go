@@ -0,0 +1,159 @@
+package hlb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestConditionalError(t *testing.T) {
+	tests := []struct {
+		name         string
+		header       http.Header
+		status       int
+		wantConflict bool
+	}{
+		{name: "precondition failed", header: ifMatch(`"v1"`), status: http.StatusPreconditionFailed, wantConflict: true},
+		{name: "conflict", header: ifMatch(`"v1"`), status: http.StatusConflict},
+		{name: "precondition failed without If-Match", header: nil, status: http.StatusPreconditionFailed},
+		{name: "not found", header: ifMatch(`"v1"`), status: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiErr := &APIErrorResponse{StatusCode: tt.status}
+			err := conditionalError(tt.header, apiErr)
+
+			var conflict *ConflictError
+			if got := errors.As(err, &conflict); got != tt.wantConflict {
+				t.Fatalf("conditionalError() = %v, want a ConflictError: %t", err, tt.wantConflict)
+			}
+			if tt.wantConflict && conflict.ETag != `"v1"` {
+				t.Errorf("ConflictError.ETag = %q, want %q", conflict.ETag, `"v1"`)
+			}
+			if !errors.Is(err, apiErr) {
+				t.Errorf("conditionalError() = %v, want it to wrap the API error", err)
+			}
+		})
+	}
+}
+
+func TestETagFromResponse(t *testing.T) {
+	// Every update makes a new version, the ETag of the version is sent with every response
+	var ifMatches []string
+	client := newTestClient(t, func(req *http.Request) *http.Response {
+		if req.Method == http.MethodPut {
+			ifMatches = append(ifMatches, req.Header.Get(ifMatchHeader))
+		}
+		resp := testResponse(req, http.StatusOK, `{"id":"lb-1","state":"active"}`)
+		resp.Header.Set(etagHeader, fmt.Sprintf(`"v%d"`, len(ifMatches)))
+		return resp
+	})
+
+	updated, err := client.UpdateLoadBalancer(context.Background(), "lb-1", &LoadBalancerUpdate{})
+	if err != nil {
+		t.Fatalf("UpdateLoadBalancer() error = %v", err)
+	}
+	if updated.ETag() != `"v1"` {
+		t.Errorf("UpdateLoadBalancer().ETag() = %q, want %q", updated.ETag(), `"v1"`)
+	}
+
+	if _, err := client.UpdateLoadBalancer(context.Background(), "lb-1", &LoadBalancerUpdate{IfMatch: updated.ETag()}); err != nil {
+		t.Fatalf("UpdateLoadBalancer() error = %v", err)
+	}
+	if want := []string{"", `"v1"`}; !slices.Equal(ifMatches, want) {
+		t.Errorf("If-Match headers = %q, want %q", ifMatches, want)
+	}
+}
+
+func TestVersionWithoutETag(t *testing.T) {
+	// The API sends no ETag header, the version is derived from the update time of the resource
+	const (
+		readAt    = `"updatedAt":"2026-10-01T12:00:00Z"`
+		changedAt = `"updatedAt":"2026-10-02T08:30:00Z"`
+	)
+
+	tests := []struct {
+		name         string
+		updatedAt    string // update time of the resource when the update is sent
+		wantConflict bool
+	}{
+		{name: "unchanged", updatedAt: readAt},
+		{name: "changed since read", updatedAt: changedAt, wantConflict: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var puts []*http.Request
+			updatedAt := readAt
+			client := newTestClient(t, func(req *http.Request) *http.Response {
+				if req.Method == http.MethodPut {
+					puts = append(puts, req)
+				}
+				return testResponse(req, http.StatusOK, `{"id":"lis-1","protocol":"HTTP",`+updatedAt+`}`)
+			})
+
+			listener, err := client.GetListener(context.Background(), "lb-1", "lis-1")
+			if err != nil {
+				t.Fatalf("GetListener() error = %v", err)
+			}
+			if listener.ETag() == "" {
+				t.Fatal("ETag() is empty for a listener read without an ETag header")
+			}
+
+			updatedAt = tt.updatedAt
+			_, err = client.UpdateListener(context.Background(), "lb-1", "lis-1", &ListenerUpdate{IfMatch: listener.ETag()})
+
+			var conflict *ConflictError
+			if got := errors.As(err, &conflict); got != tt.wantConflict {
+				t.Fatalf("UpdateListener() error = %v, want a ConflictError: %t", err, tt.wantConflict)
+			}
+			if tt.wantConflict {
+				if len(puts) != 0 {
+					t.Errorf("sent %d PUT requests after a conflict, want none", len(puts))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("UpdateListener() error = %v", err)
+			}
+			if len(puts) != 1 {
+				t.Fatalf("sent %d PUT requests, want 1", len(puts))
+			}
+			if got := puts[0].Header.Get(ifMatchHeader); got != "" {
+				t.Errorf("If-Match = %q, want the version derived by the client not to be sent", got)
+			}
+		})
+	}
+}
+
+func TestLoadBalancerVersion(t *testing.T) {
+	updatedAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	base := LoadBalancer{ID: "lb-1", UpdatedAt: updatedAt, DeploymentStatus: &DeploymentStatus{Version: "3"}}
+
+	redeployed := base
+	redeployed.DeploymentStatus = &DeploymentStatus{Version: "4"}
+	updated := base
+	updated.UpdatedAt = updatedAt.Add(time.Minute)
+	withETag := base
+	withETag.etag = `"v1"`
+
+	if base.ETag() == "" {
+		t.Fatal("ETag() is empty for a load balancer read without an ETag header")
+	}
+	for name, lb := range map[string]LoadBalancer{"redeployed": redeployed, "updated": updated} {
+		if lb.ETag() == base.ETag() {
+			t.Errorf("ETag() of the %s load balancer = %q, want it to differ", name, lb.ETag())
+		}
+	}
+	if withETag.ETag() != `"v1"` {
+		t.Errorf("ETag() = %q, want the ETag header %q", withETag.ETag(), `"v1"`)
+	}
+	if (&LoadBalancer{ID: "lb-1"}).ETag() != "" {
+		t.Error("ETag() is not empty for a load balancer without a version")
+	}
+}
@@ -6,6 +6,10 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -13,6 +17,9 @@ import (
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	ini "gopkg.in/ini.v1"
 )
 
@@ -20,26 +27,46 @@ const (
 	credentialsDir   = ".hlb"
 	credentialsFile  = "credentials"
 	defaultSection   = "default"
-	expiryDuration   = 15 * time.Minute
 	hlbAdminUserRole = "arn:aws:iam::%s:role/hlb/hlb-admin-users-role"
+
+	// expiryDuration is only used when the expiry cannot be read from the presigned URL
+	expiryDuration = 15 * time.Minute
+	// DefaultCredentialsRefreshMargin is how long before expiry the STS headers are regenerated
+	DefaultCredentialsRefreshMargin = 1 * time.Minute
+
+	amzDateFormat = "20060102T150405Z"
+
+	headerKeySuffix    = "_x_sts_gci_headers"
+	expiryKeySuffix    = "_expiry"
+	partitionKeySuffix = "_partition"
 )
 
 type Credentials struct {
 	APIKey         string
+	Hostname       string
 	XSTSGCIHeaders string
 	Expiry         time.Time
 	AccountID      string
+	Partition      string
+
+	mu sync.Mutex
 }
 
-func getSCDIHeader(ctx context.Context, cfg aws.Config, credentials *Credentials, hostname string) (string, error) {
-	if time.Now().After(credentials.Expiry) {
-		headers, err := generateSTSHeaders(ctx, cfg, credentials.AccountID, hostname)
+// getSCDIHeader returns the cached STS headers, regenerating them when they are due to expire
+// within refreshMargin so that requests issued at the end of the validity window (or retried
+// by a long-running waiter) never carry headers that expire in flight.
+func getSCDIHeader(ctx context.Context, cfg aws.Config, credentials *Credentials, hostname string, refreshMargin time.Duration) (string, error) {
+	credentials.mu.Lock()
+	defer credentials.mu.Unlock()
+
+	if time.Now().Add(refreshMargin).After(credentials.Expiry) {
+		headers, expiry, err := generateSTSHeaders(ctx, cfg, credentials.AccountID, hostname)
 		if err != nil {
 			return "", fmt.Errorf("failed to generate STS headers: %w", err)
 		}
 
 		credentials.XSTSGCIHeaders = headers
-		credentials.Expiry = time.Now().Add(expiryDuration)
+		credentials.Expiry = expiry
 
 		if err := saveCredentials(credentials, cfg.Region); err != nil {
 			return "", fmt.Errorf("failed to save credentials: %w", err)
@@ -48,10 +75,52 @@ func getSCDIHeader(ctx context.Context, cfg aws.Config, credentials *Credentials
 	return credentials.XSTSGCIHeaders, nil
 }
 
-func loadOrCreateCredentials(ctx context.Context, apiKey string, cfg aws.Config, hostname string) (*Credentials, error) {
+// invalidate forces the next getSCDIHeader call to regenerate the STS headers. It is a no-op
+// if headers have already been replaced by a concurrent refresh.
+func (c *Credentials) invalidate(headers string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.XSTSGCIHeaders == headers {
+		c.Expiry = time.Time{}
+	}
+}
+
+// stsHeadersExpiry computes the expiry of presigned STS headers from their X-Amz-Date and
+// X-Amz-Expires query parameters.
+func stsHeadersExpiry(headers string) (time.Time, error) {
+	query, err := url.ParseQuery(headers)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse STS headers: %w", err)
+	}
+
+	signedAt, err := time.Parse(amzDateFormat, query.Get("X-Amz-Date"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid X-Amz-Date in STS headers: %w", err)
+	}
+
+	expires, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid X-Amz-Expires in STS headers: %w", err)
+	}
+
+	return signedAt.Add(time.Duration(expires) * time.Second), nil
+}
+
+// CachedCredentials describes one entry of the credentials cache in ~/.hlb/credentials
+type CachedCredentials struct {
+	APIKey    string    `json:"apiKey"`
+	AccountID string    `json:"accountId"`
+	Region    string    `json:"region"`
+	Hostname  string    `json:"hostname,omitempty"`
+	Partition string    `json:"partition,omitempty"`
+	Expiry    time.Time `json:"expiry"`
+}
+
+func loadOrCreateCredentials(ctx context.Context, apiKey string, cfg aws.Config, partition, hostname string) (*Credentials, error) {
 	var credentials *Credentials
 	var accountID string
-	credentials, err := loadCredentials(apiKey, cfg.Region)
+	credentials, err := loadCredentials(apiKey, cfg.Region, hostname)
 	if err != nil {
 		return nil, err
 	}
@@ -65,15 +134,17 @@ func loadOrCreateCredentials(ctx context.Context, apiKey string, cfg aws.Config,
 		accountID = *result.Account
 
 		// Use the provided hostname for initial credentials
-		headers, err := generateSTSHeaders(ctx, cfg, accountID, hostname)
+		headers, expiry, err := generateSTSHeaders(ctx, cfg, accountID, hostname)
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate STS headers: %w", err)
 		}
 		credentials = &Credentials{
 			APIKey:         apiKey,
+			Hostname:       hostname,
 			XSTSGCIHeaders: headers,
-			Expiry:         time.Now().Add(expiryDuration),
+			Expiry:         expiry,
 			AccountID:      accountID,
+			Partition:      partition,
 		}
 		if err := saveCredentials(credentials, cfg.Region); err != nil {
 			return nil, fmt.Errorf("failed to save credentials: %w", err)
@@ -82,7 +153,25 @@ func loadOrCreateCredentials(ctx context.Context, apiKey string, cfg aws.Config,
 	return credentials, nil
 }
 
-func loadCredentials(apiKey, region string) (*Credentials, error) {
+// cacheScope prefixes the keys of the cache entry of region and hostname. STS headers are signed
+// for the hostname of the API, so headers cached for another endpoint of the same region, such as
+// one set with WithEndpoint, are never reused.
+func cacheScope(region, hostname string) string {
+	return region + "@" + hostname
+}
+
+// parseCacheKey returns the region and hostname of a cache key ending with suffix. Entries cached
+// before the hostname was part of the key have no hostname.
+func parseCacheKey(key, suffix string) (region, hostname string, ok bool) {
+	scope, ok := strings.CutSuffix(key, suffix)
+	if !ok {
+		return "", "", false
+	}
+	region, hostname, _ = strings.Cut(scope, "@")
+	return region, hostname, true
+}
+
+func loadCredentials(apiKey, region, hostname string) (*Credentials, error) {
 	credPath := getCredentialsPath()
 	cfg, err := ini.Load(credPath)
 	if err != nil {
@@ -93,18 +182,26 @@ func loadCredentials(apiKey, region string) (*Credentials, error) {
 	}
 
 	section := cfg.Section(apiKey)
-	headerKey := fmt.Sprintf("%s_x_sts_gci_headers", region)
-	expiryKey := fmt.Sprintf("%s_expiry", region)
+	scope := cacheScope(region, hostname)
+	headerKey := scope + headerKeySuffix
+	expiryKey := scope + expiryKeySuffix
 	if section == nil || section.Key("account_id").String() == "" || section.Key(headerKey).String() == "" {
 		return nil, nil
 	}
 
-	expiry, _ := time.Parse(time.RFC3339, section.Key(expiryKey).String())
+	// Prefer the expiry signed into the headers over the one recorded in the file
+	headers := section.Key(headerKey).String()
+	expiry, err := stsHeadersExpiry(headers)
+	if err != nil {
+		expiry, _ = time.Parse(time.RFC3339, section.Key(expiryKey).String())
+	}
 	return &Credentials{
 		APIKey:         apiKey,
-		XSTSGCIHeaders: section.Key(headerKey).String(),
+		Hostname:       hostname,
+		XSTSGCIHeaders: headers,
 		Expiry:         expiry,
 		AccountID:      section.Key("account_id").String(),
+		Partition:      section.Key(scope + partitionKeySuffix).String(),
 	}, nil
 }
 
@@ -123,10 +220,12 @@ func saveCredentials(creds *Credentials, region string) error {
 	if err != nil {
 		return fmt.Errorf("failed to create section in credentials file: %w", err)
 	}
-	headerKey := fmt.Sprintf("%s_x_sts_gci_headers", region)
-	expiryKey := fmt.Sprintf("%s_expiry", region)
-	section.NewKey(headerKey, creds.XSTSGCIHeaders)
-	section.NewKey(expiryKey, creds.Expiry.Format(time.RFC3339))
+	scope := cacheScope(region, creds.Hostname)
+	section.NewKey(scope+headerKeySuffix, creds.XSTSGCIHeaders)
+	section.NewKey(scope+expiryKeySuffix, creds.Expiry.Format(time.RFC3339))
+	if creds.Partition != "" {
+		section.NewKey(scope+partitionKeySuffix, creds.Partition)
+	}
 	section.NewKey("account_id", creds.AccountID)
 
 	if err := ensureCredentialsDir(); err != nil {
@@ -139,6 +238,105 @@ func saveCredentials(creds *Credentials, region string) error {
 	return nil
 }
 
+// ListCachedCredentials returns every entry of the credentials cache, one per API key, region and
+// hostname
+func ListCachedCredentials() ([]CachedCredentials, error) {
+	cfg, err := ini.Load(getCredentialsPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to load credentials file: %w", err)
+	}
+
+	var entries []CachedCredentials
+	for _, section := range cfg.Sections() {
+		if section.Name() == ini.DefaultSection {
+			continue
+		}
+		for _, key := range section.Keys() {
+			region, hostname, ok := parseCacheKey(key.Name(), headerKeySuffix)
+			if !ok {
+				continue
+			}
+			scope := strings.TrimSuffix(key.Name(), headerKeySuffix)
+			expiry, err := stsHeadersExpiry(key.String())
+			if err != nil {
+				expiry, _ = time.Parse(time.RFC3339, section.Key(scope+expiryKeySuffix).String())
+			}
+			entries = append(entries, CachedCredentials{
+				APIKey:    section.Name(),
+				AccountID: section.Key("account_id").String(),
+				Region:    region,
+				Hostname:  hostname,
+				Partition: section.Key(scope + partitionKeySuffix).String(),
+				Expiry:    expiry,
+			})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].APIKey != entries[j].APIKey {
+			return entries[i].APIKey < entries[j].APIKey
+		}
+		if entries[i].Region != entries[j].Region {
+			return entries[i].Region < entries[j].Region
+		}
+		return entries[i].Hostname < entries[j].Hostname
+	})
+	return entries, nil
+}
+
+// ClearCachedCredentials removes entries from the credentials cache. An empty apiKey clears the
+// whole cache, an empty region clears every region cached for apiKey. Clearing a region clears it
+// for every hostname.
+func ClearCachedCredentials(apiKey, region string) error {
+	credPath := getCredentialsPath()
+	if apiKey == "" {
+		if err := os.Remove(credPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove credentials file: %w", err)
+		}
+		return nil
+	}
+
+	cfg, err := ini.Load(credPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to load credentials file: %w", err)
+	}
+
+	if region == "" {
+		cfg.DeleteSection(apiKey)
+	} else if section, err := cfg.GetSection(apiKey); err == nil {
+		for _, name := range section.KeyStrings() {
+			for _, suffix := range []string{headerKeySuffix, expiryKeySuffix, partitionKeySuffix} {
+				if keyRegion, _, ok := parseCacheKey(name, suffix); ok && keyRegion == region {
+					section.DeleteKey(name)
+				}
+			}
+		}
+
+		// Drop the section entirely once no region is left in it
+		remaining := false
+		for _, key := range section.Keys() {
+			if strings.HasSuffix(key.Name(), headerKeySuffix) {
+				remaining = true
+				break
+			}
+		}
+		if !remaining {
+			cfg.DeleteSection(apiKey)
+		}
+	}
+
+	if err := cfg.SaveTo(credPath); err != nil {
+		return fmt.Errorf("failed to save credentials file: %w", err)
+	}
+	return nil
+}
+
 func getCredentialsPath() string {
 	homeDir, _ := os.UserHomeDir()
 	return filepath.Join(homeDir, credentialsDir, credentialsFile)
@@ -148,9 +346,8 @@ func getSTSClient(ctx context.Context, cfg aws.Config, accountID string) (*sts.C
 	stsClient := sts.NewFromConfig(cfg)
 
 	// Assume the hlbAdminUserRole
-	roleARN := fmt.Sprintf(hlbAdminUserRole, accountID)
 	assumeRoleInput := &sts.AssumeRoleInput{
-		RoleArn:         aws.String(roleARN),
+		RoleArn:         aws.String(hlbRoleARN(accountID)),
 		RoleSessionName: aws.String("HLBTerraformProviderSession"),
 	}
 
@@ -179,10 +376,24 @@ func getSTSClient(ctx context.Context, cfg aws.Config, accountID string) (*sts.C
 	return assumedSTSClient, nil
 }
 
-func generateSTSHeaders(ctx context.Context, cfg aws.Config, accountID string, hostname string) (string, error) {
+// hlbRoleARN returns the ARN of the role assumed to sign API requests for accountID
+func hlbRoleARN(accountID string) string {
+	return fmt.Sprintf(hlbAdminUserRole, accountID)
+}
+
+// generateSTSHeaders presigns a GetCallerIdentity request for hostname and returns its query
+// string along with the time at which the signature expires.
+func generateSTSHeaders(ctx context.Context, cfg aws.Config, accountID string, hostname string) (_ string, _ time.Time, err error) {
+	ctx, span := tracer.Start(ctx, "GenerateSTSHeaders", trace.WithAttributes(semconv.CloudRegion(cfg.Region)))
+	start := time.Now()
+	defer func() {
+		endSpan(span, err)
+		presignDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(outcomeKey.String(outcome(err))))
+	}()
+
 	assumedSTSClient, err := getSTSClient(ctx, cfg, accountID)
 	if err != nil {
-		return "", err
+		return "", time.Time{}, err
 	}
 
 	presigner := sts.NewPresignClient(assumedSTSClient)
@@ -193,18 +404,24 @@ func generateSTSHeaders(ctx context.Context, cfg aws.Config, accountID string, h
 		})
 	})
 	if err != nil {
-		return "", fmt.Errorf("failed to presign request: %w", err)
+		return "", time.Time{}, fmt.Errorf("failed to presign request: %w", err)
 	}
 
 	// Extract query string from presigned URL
 	parsedURL, err := url.Parse(presignedURL.URL)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse presigned URL: %w", err)
+		return "", time.Time{}, fmt.Errorf("failed to parse presigned URL: %w", err)
 	}
 
 	headers := parsedURL.Query().Encode()
 
-	return headers, nil
+	expiry, err := stsHeadersExpiry(headers)
+	if err != nil {
+		// Fall back to the default STS presign lifetime
+		expiry = time.Now().Add(expiryDuration)
+	}
+
+	return headers, expiry, nil
 }
 
 func ensureCredentialsDir() error {
@@ -0,0 +1,138 @@
+package hlb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSTSHeadersExpiry(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers string
+		want    time.Time
+		wantErr bool
+	}{
+		{
+			name:    "signed headers",
+			headers: "Action=GetCallerIdentity&Version=2011-06-15&X-Amz-Date=20260102T030405Z&X-Amz-Expires=900&X-Amz-Signature=abc",
+			want:    time.Date(2026, 1, 2, 3, 19, 5, 0, time.UTC),
+		},
+		{
+			name:    "missing date",
+			headers: "X-Amz-Expires=900",
+			wantErr: true,
+		},
+		{
+			name:    "invalid date",
+			headers: "X-Amz-Date=2026-01-02T03:04:05Z&X-Amz-Expires=900",
+			wantErr: true,
+		},
+		{
+			name:    "missing expires",
+			headers: "X-Amz-Date=20260102T030405Z",
+			wantErr: true,
+		},
+		{
+			name:    "invalid expires",
+			headers: "X-Amz-Date=20260102T030405Z&X-Amz-Expires=15m",
+			wantErr: true,
+		},
+		{
+			name:    "invalid query",
+			headers: "X-Amz-Date=%zz",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := stsHeadersExpiry(tt.headers)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("stsHeadersExpiry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("stsHeadersExpiry() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCredentialsInvalidate(t *testing.T) {
+	expiry := time.Now().Add(time.Hour)
+	tests := []struct {
+		name       string
+		headers    string
+		wantExpiry time.Time
+	}{
+		{name: "rejected headers", headers: "current", wantExpiry: time.Time{}},
+		{name: "headers already refreshed", headers: "previous", wantExpiry: expiry},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Credentials{XSTSGCIHeaders: "current", Expiry: expiry}
+			c.invalidate(tt.headers)
+			if !c.Expiry.Equal(tt.wantExpiry) {
+				t.Errorf("Expiry = %v, want %v", c.Expiry, tt.wantExpiry)
+			}
+		})
+	}
+}
+
+func TestCredentialsCacheHostname(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	const headers = "X-Amz-Date=20260102T030405Z&X-Amz-Expires=900"
+	hostnames := []string{"api.hlb.us-east-1.aws.example.com", "127.0.0.1:8080"}
+	for _, hostname := range hostnames {
+		creds := &Credentials{APIKey: "key", Hostname: hostname, XSTSGCIHeaders: headers + "&Host=" + hostname, AccountID: "123456789012"}
+		if err := saveCredentials(creds, "us-east-1"); err != nil {
+			t.Fatalf("saveCredentials() error = %v", err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		region      string
+		hostname    string
+		wantHeaders string
+	}{
+		{name: "regional endpoint", region: "us-east-1", hostname: hostnames[0], wantHeaders: headers + "&Host=" + hostnames[0]},
+		{name: "endpoint with a port", region: "us-east-1", hostname: hostnames[1], wantHeaders: headers + "&Host=" + hostnames[1]},
+		{name: "other hostname", region: "us-east-1", hostname: "hlb.example.com"},
+		{name: "other region", region: "eu-west-1", hostname: hostnames[0]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			creds, err := loadCredentials("key", tt.region, tt.hostname)
+			if err != nil {
+				t.Fatalf("loadCredentials() error = %v", err)
+			}
+			if tt.wantHeaders == "" {
+				if creds != nil {
+					t.Errorf("loadCredentials() = %+v, want no cached credentials", creds)
+				}
+				return
+			}
+			if creds == nil || creds.XSTSGCIHeaders != tt.wantHeaders {
+				t.Errorf("loadCredentials() = %+v, want headers %q", creds, tt.wantHeaders)
+			}
+		})
+	}
+
+	entries, err := ListCachedCredentials()
+	if err != nil {
+		t.Fatalf("ListCachedCredentials() error = %v", err)
+	}
+	if len(entries) != 2 || entries[0].Hostname != hostnames[1] || entries[1].Hostname != hostnames[0] {
+		t.Errorf("ListCachedCredentials() = %+v, want one entry per hostname", entries)
+	}
+
+	if err := ClearCachedCredentials("key", "us-east-1"); err != nil {
+		t.Fatalf("ClearCachedCredentials() error = %v", err)
+	}
+	if entries, _ := ListCachedCredentials(); len(entries) != 0 {
+		t.Errorf("ListCachedCredentials() after clearing the region = %+v, want none", entries)
+	}
+}
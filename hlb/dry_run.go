@@ -0,0 +1,126 @@
+package hlb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+const (
+	// DryRunLoadBalancerID and DryRunListenerID are the IDs of resources created in dry-run mode
+	DryRunLoadBalancerID = "lb-dry-run"
+	DryRunListenerID     = "lis-dry-run"
+)
+
+// WithDryRun makes the client write the POST, PUT and DELETE requests it would send to w instead of
+// sending them, and return synthetic responses. Read requests are still sent, so that the
+// responses to updates reflect the current state of the resources.
+func WithDryRun(w io.Writer) ClientOption {
+	return func(c *Client) {
+		c.dryRun = w
+	}
+}
+
+// IsDryRun reports whether the client was created with WithDryRun
+func (c *Client) IsDryRun() bool {
+	return c.dryRun != nil
+}
+
+// dryRunRequest writes a mutating request and returns the response the API would likely send
+func (c *Client) dryRunRequest(ctx context.Context, method, path string, payload []byte, header http.Header) (*http.Response, error) {
+	fmt.Fprintf(c.dryRun, "[dry-run] %s %s%s\n", method, c.baseURL, path)
+	if c.headers.Get("User-Agent") == "" {
+		fmt.Fprintf(c.dryRun, "[dry-run] User-Agent: %s\n", c.UserAgent())
+	}
+	writeDryRunHeaders(c.dryRun, c.headers)
+	writeDryRunHeaders(c.dryRun, header)
+	fmt.Fprintf(c.dryRun, "[dry-run] Content-Type: application/json\n")
+	fmt.Fprintf(c.dryRun, "[dry-run] x-api-key: %s\n", RedactSecret(c.apiKey))
+	fmt.Fprintf(c.dryRun, "[dry-run] X-Sts-Gci-Headers: <redacted>\n")
+	if payload != nil {
+		var indented bytes.Buffer
+		if json.Indent(&indented, payload, "[dry-run] ", "  ") == nil {
+			fmt.Fprintf(c.dryRun, "[dry-run] %s\n", indented.String())
+		}
+	}
+
+	var body map[string]interface{}
+	switch method {
+	case http.MethodDelete:
+		return syntheticResponse(http.StatusNoContent, nil)
+	case http.MethodPost:
+		body = map[string]interface{}{}
+		if strings.HasSuffix(path, "/listeners") {
+			body["id"] = DryRunListenerID
+		} else {
+			body["id"] = DryRunLoadBalancerID
+			body["accountId"] = c.accountID
+			body["state"] = LBStateActive
+		}
+	case http.MethodPut:
+		// Start from the current resource so that the response holds the fields left unchanged
+		resp, err := c.sendRequest(ctx, http.MethodGet, path, nil)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+
+	var changes map[string]interface{}
+	if payload != nil {
+		if err := json.Unmarshal(payload, &changes); err != nil {
+			return nil, fmt.Errorf("failed to decode request body: %w", err)
+		}
+	}
+	for k, v := range changes {
+		// Updates send null for the fields they leave unchanged
+		if v != nil {
+			body[k] = v
+		}
+	}
+	return syntheticResponse(http.StatusOK, body)
+}
+
+// writeDryRunHeaders writes header with the credentials redacted
+func writeDryRunHeaders(w io.Writer, header http.Header) {
+	for _, k := range slices.Sorted(maps.Keys(header)) {
+		for _, v := range header[k] {
+			if isRedactedHeader(k) {
+				v = "<redacted>"
+			}
+			fmt.Fprintf(w, "[dry-run] %s: %s\n", k, v)
+		}
+	}
+}
+
+func syntheticResponse(status int, body interface{}) (*http.Response, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("failed to encode dry-run response: %w", err)
+		}
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(bytes.NewReader(data)),
+	}, nil
+}
+
+// RedactSecret keeps the first characters of a secret, enough to tell secrets apart in logs
+func RedactSecret(secret string) string {
+	if len(secret) <= 4 {
+		return strings.Repeat("*", len(secret))
+	}
+	return secret[:4] + strings.Repeat("*", 8)
+}
@@ -1,13 +1,122 @@
 package hlb
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
 
 // APIErrorResponse represents an error from the HLB API
 type APIErrorResponse struct {
 	Code    int    `json:"code"`    // Error code from the API
 	Message string `json:"message"` // Error message from the API
+
+	StatusCode int    `json:"-"` // HTTP status of the response
+	RequestID  string `json:"-"` // ID of the request, for support requests
 }
 
 func (e *APIErrorResponse) Error() string {
 	return fmt.Sprintf("API error %d: %s", e.Code, e.Message)
 }
+
+// LoadBalancerFailedError is returned by waiters when a load balancer enters the failed state
+type LoadBalancerFailedError struct {
+	ID      string // ID of the load balancer
+	Message string // Error message from the load balancer deployment status
+}
+
+func (e *LoadBalancerFailedError) Error() string {
+	return fmt.Sprintf("load balancer (%s) entered failed state, with message '%s'", e.ID, e.Message)
+}
+
+// IncompleteCreateError is returned by CreateLoadBalancer when the load balancer was created but
+// waiting for it to become active failed, such as when it entered the failed state, the wait timed
+// out or it was canceled
+type IncompleteCreateError struct {
+	ID  string // ID of the created load balancer
+	Err error
+}
+
+func (e *IncompleteCreateError) Error() string {
+	return fmt.Sprintf("load balancer (%s) was created but did not become active: %s", e.ID, e.Err)
+}
+
+func (e *IncompleteCreateError) Unwrap() error {
+	return e.Err
+}
+
+// CredentialsError is returned when the STS headers authenticating requests cannot be generated
+type CredentialsError struct {
+	Err error
+}
+
+func (e *CredentialsError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CredentialsError) Unwrap() error {
+	return e.Err
+}
+
+// ConflictError is returned by updates rejected because the resource changed since the version
+// they were based on was read
+type ConflictError struct {
+	ETag    string            // ETag the update was based on
+	Current string            // version read before the update, when the API sent no ETag
+	Err     *APIErrorResponse // error of the API, nil when the conflict was found by the client
+}
+
+func (e *ConflictError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("resource changed since version %s was read, it is now at version %s", e.ETag, e.Current)
+	}
+	return fmt.Sprintf("resource changed since version %s was read: %s", e.ETag, e.Err)
+}
+
+func (e *ConflictError) Unwrap() error {
+	if e.Err == nil {
+		return nil
+	}
+	return e.Err
+}
+
+// IsNotFound reports whether err is an API error for a resource that does not exist
+func IsNotFound(err error) bool {
+	var apiErr *APIErrorResponse
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
+
+// IsThrottled reports whether err is an API error for a request that was still rate limited after
+// every retry
+func IsThrottled(err error) bool {
+	var apiErr *APIErrorResponse
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests
+}
+
+// IsConflict reports whether err is a ConflictError
+func IsConflict(err error) bool {
+	var conflictErr *ConflictError
+	return errors.As(err, &conflictErr)
+}
+
+// IsOutcomeUnknown reports whether err leaves it unknown if the API processed the request: the
+// request failed in transit, or a gateway answered 502, 503 or 504 after every retry. A create
+// request failing this way can be sent again with the same client token to resume the creation.
+func IsOutcomeUnknown(err error) bool {
+	var incompleteErr *IncompleteCreateError
+	if errors.As(err, &incompleteErr) {
+		return false
+	}
+	var apiErr *APIErrorResponse
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var urlErr *url.Error
+	return errors.As(err, &urlErr) && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
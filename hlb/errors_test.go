@@ -0,0 +1,42 @@
+package hlb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestIsOutcomeUnknown(t *testing.T) {
+	transportErr := &url.Error{Op: "Post", URL: "https://hlb.example.com/v1", Err: errors.New("connection reset by peer")}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "success", err: nil, want: false},
+		{name: "transport error", err: fmt.Errorf("failed to send request: %w", transportErr), want: true},
+		{name: "canceled", err: &url.Error{Op: "Post", URL: "https://hlb.example.com/v1", Err: context.Canceled}, want: false},
+		{name: "deadline exceeded", err: &url.Error{Op: "Post", URL: "https://hlb.example.com/v1", Err: context.DeadlineExceeded}, want: false},
+		{name: "bad gateway", err: &APIErrorResponse{StatusCode: http.StatusBadGateway}, want: true},
+		{name: "service unavailable", err: &APIErrorResponse{StatusCode: http.StatusServiceUnavailable}, want: true},
+		{name: "gateway timeout", err: &APIErrorResponse{StatusCode: http.StatusGatewayTimeout}, want: true},
+		{name: "internal server error", err: &APIErrorResponse{StatusCode: http.StatusInternalServerError}, want: false},
+		{name: "bad request", err: &APIErrorResponse{StatusCode: http.StatusBadRequest}, want: false},
+		{name: "credentials", err: &CredentialsError{Err: errors.New("no credentials")}, want: false},
+		{name: "load balancer failed", err: &LoadBalancerFailedError{ID: "lb-1"}, want: false},
+		{name: "created but not active", err: &IncompleteCreateError{ID: "lb-1", Err: fmt.Errorf("failed to send request: %w", transportErr)}, want: false},
+		{name: "other error", err: errors.New("failed to decode response"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsOutcomeUnknown(tt.err); got != tt.want {
+				t.Errorf("IsOutcomeUnknown(%v) = %t, want %t", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package hlb
+
+import (
+	"context"
+	"crypto/rand"
+	"net/http"
+)
+
+// idempotencyKeyHeader carries the client token of create requests. The API creates at most one
+// resource per token and answers the requests repeating a token with the resource it created.
+const idempotencyKeyHeader = "Idempotency-Key"
+
+type idempotentRequestKey struct{}
+
+// NewClientToken returns a random token identifying one logical create operation. Callers that
+// may repeat the operation, such as after a crash, should store it and pass it again.
+func NewClientToken() string {
+	return rand.Text()
+}
+
+// idempotencyHeader returns the header sending token, or a new token when it is empty
+func idempotencyHeader(token string) http.Header {
+	if token == "" {
+		token = NewClientToken()
+	}
+	return http.Header{idempotencyKeyHeader: []string{token}}
+}
+
+// withIdempotentRequest marks the requests sent with ctx as safe to send again
+func withIdempotentRequest(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentRequestKey{}, true)
+}
+
+// isIdempotentRequest reports whether the request sent with ctx can be sent again when its outcome
+// is unknown
+func isIdempotentRequest(ctx context.Context) bool {
+	idempotent, _ := ctx.Value(idempotentRequestKey{}).(bool)
+	return idempotent
+}
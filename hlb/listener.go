@@ -21,9 +21,17 @@ type Listener struct {
 	TargetGroupARN           string    `json:"targetGroupArn"`
 	UpdatedAt                time.Time `json:"updatedAt"`
 	URI                      string    `json:"uri"`
+
+	etag string
 }
 
 type ListenerCreate struct {
+	// ClientToken makes the creation safe to retry: the API creates a single listener per token.
+	// When it is empty, CreateListener uses a new token for its own retries only. Set it to send the
+	// creation again, such as after an error for which IsOutcomeUnknown is true, and reset it before
+	// reusing the input for another listener.
+	ClientToken string `json:"-"`
+
 	ALPNPolicy               string  `json:"alpnPolicy,omitempty"`
 	CertificateSecretsName   string  `json:"certificateSecretsName,omitempty"`
 	EnableDeletionProtection bool    `json:"enableDeletionProtection"`
@@ -34,6 +42,10 @@ type ListenerCreate struct {
 }
 
 type ListenerUpdate struct {
+	// IfMatch is the ETag of the version of the listener the update is based on, as returned by
+	// Listener.ETag. When set, the update fails with a ConflictError if the listener changed since.
+	IfMatch string `json:"-"`
+
 	ALPNPolicy               *string  `json:"alpnPolicy,omitempty"`
 	CertificateSecretsName   *string  `json:"certificateSecretsName,omitempty"`
 	EnableDeletionProtection *bool    `json:"enableDeletionProtection"`
@@ -44,7 +56,8 @@ type ListenerUpdate struct {
 }
 
 func (c *Client) CreateListener(ctx context.Context, loadBalancerID string, input *ListenerCreate) (*Listener, error) {
-	resp, err := c.sendRequest(ctx, "POST", fmt.Sprintf("/aws_account/%s/load-balancers/%s/listeners", c.accountID, loadBalancerID), input)
+	header := idempotencyHeader(input.ClientToken)
+	resp, err := c.sendRequestWithHeader(ctx, "POST", fmt.Sprintf("/aws_account/%s/load-balancers/%s/listeners", c.accountID, loadBalancerID), input, header)
 	if err != nil {
 		return nil, err
 	}
@@ -54,6 +67,7 @@ func (c *Client) CreateListener(ctx context.Context, loadBalancerID string, inpu
 	if err := json.NewDecoder(resp.Body).Decode(&listener); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
+	listener.etag = resp.Header.Get(etagHeader)
 
 	return &listener, nil
 }
@@ -69,12 +83,24 @@ func (c *Client) GetListener(ctx context.Context, loadBalancerID, listenerID str
 	if err := json.NewDecoder(resp.Body).Decode(&listener); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
+	listener.etag = resp.Header.Get(etagHeader)
 
 	return &listener, nil
 }
 
 func (c *Client) UpdateListener(ctx context.Context, loadBalancerID, listenerID string, input *ListenerUpdate) (*Listener, error) {
-	resp, err := c.sendRequest(ctx, "PUT", fmt.Sprintf("/aws_account/%s/load-balancers/%s/listeners/%s", c.accountID, loadBalancerID, listenerID), input)
+	header, err := precondition(ctx, input.IfMatch, func(ctx context.Context) (string, error) {
+		listener, err := c.GetListener(ctx, loadBalancerID, listenerID)
+		if err != nil {
+			return "", err
+		}
+		return listener.version(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.sendRequestWithHeader(ctx, "PUT", fmt.Sprintf("/aws_account/%s/load-balancers/%s/listeners/%s", c.accountID, loadBalancerID, listenerID), input, header)
 	if err != nil {
 		return nil, err
 	}
@@ -84,6 +110,7 @@ func (c *Client) UpdateListener(ctx context.Context, loadBalancerID, listenerID
 	if err := json.NewDecoder(resp.Body).Decode(&listener); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
+	listener.etag = resp.Header.Get(etagHeader)
 
 	return &listener, nil
 }
@@ -10,6 +10,9 @@ import (
 	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/retry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type LoadBalancer struct {
@@ -42,6 +45,8 @@ type LoadBalancer struct {
 	XffHeaderProcessingMode      string            `json:"xffHeaderProcessingMode"`
 	ZoneID                       string            `json:"zoneId"`
 	ZoneName                     string            `json:"zoneName"`
+
+	etag string
 }
 
 type DeploymentStatus struct {
@@ -64,6 +69,12 @@ type AccessLogs struct {
 }
 
 type LoadBalancerCreate struct {
+	// ClientToken makes the creation safe to retry: the API creates a single load balancer per
+	// token. When it is empty, CreateLoadBalancer uses a new token for its own retries only. Set it
+	// to send the creation again, such as after an error for which IsOutcomeUnknown is true, and
+	// reset it before reusing the input for another load balancer.
+	ClientToken string `json:"-"`
+
 	AccessLogs                   *AccessLogs       `json:"accessLogs"`
 	ClientKeepAlive              int               `json:"clientKeepAlive"`
 	ConnectionDrainingTimeout    int               `json:"connectionDrainingTimeout"`
@@ -88,6 +99,11 @@ type LoadBalancerCreate struct {
 }
 
 type LoadBalancerUpdate struct {
+	// IfMatch is the ETag of the version of the load balancer the update is based on, as returned
+	// by LoadBalancer.ETag. When set, the update fails with a ConflictError if the load balancer
+	// changed since.
+	IfMatch string `json:"-"`
+
 	AccessLogs                   *AccessLogs        `json:"accessLogs"`
 	ClientKeepAlive              *int               `json:"clientKeepAlive"`
 	ConnectionDrainingTimeout    *int               `json:"connectionDrainingTimeout"`
@@ -144,17 +160,45 @@ func isLoadBalancerInPendingState(state string) bool {
 
 // Wait for at most timeout for load balancer identified with id to enter one of the states in target[]
 func (c *Client) waitForLoadBalancerState(ctx context.Context, id string, target []string, timeout time.Duration) (*LoadBalancer, error) {
+	return c.WaitForLoadBalancerState(ctx, id, target, timeout, nil)
+}
+
+// WaitForLoadBalancerState waits for at most timeout for the load balancer identified with id to
+// enter one of the states in target. If onPoll is not nil, it is called with the load balancer
+// every time it is polled. A load balancer that can no longer be found is considered deleted.
+func (c *Client) WaitForLoadBalancerState(ctx context.Context, id string, target []string, timeout time.Duration, onPoll func(*LoadBalancer)) (_ *LoadBalancer, err error) {
+	targets := attribute.StringSlice(string(targetStatesKey), target)
+	ctx, span := tracer.Start(ctx, "WaitForLoadBalancerState", trace.WithAttributes(loadBalancerKey.String(id), targets))
+	start := time.Now()
+	polls := 0
+	var lb *LoadBalancer
+	defer func() {
+		if lb != nil {
+			span.SetAttributes(stateKey.String(lb.State))
+		}
+		span.SetAttributes(pollCountKey.Int(polls))
+		endSpan(span, err)
+		waitDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(targets, outcomeKey.String(outcome(err))))
+	}()
+
 	targetStates := make(map[string]bool, len(target))
 	for _, s := range target {
 		targetStates[s] = true
 	}
 
-	var lb *LoadBalancer
-	err := retry.RetryContext(ctx, timeout, func() *retry.RetryError {
+	err = retry.RetryContext(ctx, timeout, func() *retry.RetryError {
 		var err error
+		polls++
 		lb, err = c.GetLoadBalancer(ctx, id)
 		if err != nil {
-			return retry.NonRetryableError(err)
+			if !targetStates[LBStateDeleted] || !IsNotFound(err) {
+				return retry.NonRetryableError(err)
+			}
+			lb = &LoadBalancer{ID: id, State: LBStateDeleted}
+		}
+
+		if onPoll != nil {
+			onPoll(lb)
 		}
 
 		if lb.State == LBStateFailed {
@@ -162,7 +206,7 @@ func (c *Client) waitForLoadBalancerState(ctx context.Context, id string, target
 			if lb.DeploymentStatus != nil && lb.DeploymentStatus.ErrorMessage != "" {
 				extendedErrorMessage = lb.DeploymentStatus.ErrorMessage
 			}
-			return retry.NonRetryableError(fmt.Errorf("load balancer (%s) entered failed state, with message '%s'", id, extendedErrorMessage))
+			return retry.NonRetryableError(&LoadBalancerFailedError{ID: id, Message: extendedErrorMessage})
 		}
 
 		if targetStates[lb.State] {
@@ -207,7 +251,8 @@ func (c *Client) ListLoadBalancers(ctx context.Context, limit int, nextToken str
 }
 
 func (c *Client) CreateLoadBalancer(ctx context.Context, input *LoadBalancerCreate) (*LoadBalancer, error) {
-	resp, err := c.sendRequest(ctx, "POST", fmt.Sprintf("/aws_account/%s/load-balancers", c.accountID), input)
+	header := idempotencyHeader(input.ClientToken)
+	resp, err := c.sendRequestWithHeader(ctx, "POST", fmt.Sprintf("/aws_account/%s/load-balancers", c.accountID), input, header)
 	if err != nil {
 		return nil, err
 	}
@@ -217,9 +262,19 @@ func (c *Client) CreateLoadBalancer(ctx context.Context, input *LoadBalancerCrea
 	if err := json.NewDecoder(resp.Body).Decode(&lb); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
+	lb.etag = resp.Header.Get(etagHeader)
+
+	// Nothing was created in dry-run mode
+	if c.IsDryRun() {
+		return &lb, nil
+	}
 
 	// Wait for the load balancer to be active
-	return c.waitForLoadBalancerState(ctx, lb.ID, []string{LBStateActive}, DefaultCreateTimeout)
+	active, err := c.waitForLoadBalancerState(ctx, lb.ID, []string{LBStateActive}, DefaultCreateTimeout)
+	if err != nil {
+		return nil, &IncompleteCreateError{ID: lb.ID, Err: err}
+	}
+	return active, nil
 }
 
 func (c *Client) GetLoadBalancer(ctx context.Context, loadBalancerID string) (*LoadBalancer, error) {
@@ -233,12 +288,24 @@ func (c *Client) GetLoadBalancer(ctx context.Context, loadBalancerID string) (*L
 	if err := json.NewDecoder(resp.Body).Decode(&lb); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
+	lb.etag = resp.Header.Get(etagHeader)
 
 	return &lb, nil
 }
 
 func (c *Client) UpdateLoadBalancer(ctx context.Context, loadBalancerID string, input *LoadBalancerUpdate) (*LoadBalancer, error) {
-	resp, err := c.sendRequest(ctx, "PUT", fmt.Sprintf("/aws_account/%s/load-balancers/%s", c.accountID, loadBalancerID), input)
+	header, err := precondition(ctx, input.IfMatch, func(ctx context.Context) (string, error) {
+		lb, err := c.GetLoadBalancer(ctx, loadBalancerID)
+		if err != nil {
+			return "", err
+		}
+		return lb.version(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.sendRequestWithHeader(ctx, "PUT", fmt.Sprintf("/aws_account/%s/load-balancers/%s", c.accountID, loadBalancerID), input, header)
 	if err != nil {
 		return nil, err
 	}
@@ -248,6 +315,11 @@ func (c *Client) UpdateLoadBalancer(ctx context.Context, loadBalancerID string,
 	if err := json.NewDecoder(resp.Body).Decode(&lb); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
+	lb.etag = resp.Header.Get(etagHeader)
+
+	if c.IsDryRun() {
+		return &lb, nil
+	}
 
 	// Wait for the load balancer to be active after update
 	return c.waitForLoadBalancerState(ctx, lb.ID, []string{LBStateActive}, DefaultUpdateTimeout)
@@ -260,6 +332,10 @@ func (c *Client) DeleteLoadBalancer(ctx context.Context, loadBalancerID string)
 	}
 	defer resp.Body.Close()
 
+	if c.IsDryRun() {
+		return nil
+	}
+
 	// Wait for the load balancer to be deleted
 	_, err = c.waitForLoadBalancerState(ctx, loadBalancerID, []string{LBStateDeleted}, DefaultDeleteTimeout)
 	return err
@@ -0,0 +1,121 @@
+package hlb
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// LogLevel is the severity of a log message of the client
+type LogLevel int
+
+const (
+	// LogLevelTrace is used for request and response headers and bodies
+	LogLevelTrace LogLevel = iota
+	// LogLevelDebug is used for requests, responses, retries and credentials refreshes
+	LogLevelDebug
+	LogLevelInfo
+	LogLevelWarn
+	LogLevelError
+)
+
+// Logger receives the log messages of a Client. Secrets are redacted from the messages and fields
+// before they are passed to the logger.
+type Logger interface {
+	Log(ctx context.Context, level LogLevel, msg string, fields map[string]interface{})
+}
+
+// redactedHeaders are the headers holding credentials, including the ones that can be added with
+// WithHeaders or set by proxies
+var redactedHeaders = []string{"x-api-key", "X-Sts-Gci-Headers", "Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// WithLogger sends the log messages of the client to logger. The client does not log by default.
+func WithLogger(logger Logger) ClientOption {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// NewHCLogger returns a Logger writing to an hclog logger
+func NewHCLogger(logger hclog.Logger) Logger {
+	return hclogLogger{logger: logger}
+}
+
+type hclogLogger struct {
+	logger hclog.Logger
+}
+
+func (l hclogLogger) Log(ctx context.Context, level LogLevel, msg string, fields map[string]interface{}) {
+	args := make([]interface{}, 0, 2*len(fields))
+	for _, k := range slices.Sorted(maps.Keys(fields)) {
+		args = append(args, k, fields[k])
+	}
+
+	switch level {
+	case LogLevelTrace:
+		l.logger.Trace(msg, args...)
+	case LogLevelDebug:
+		l.logger.Debug(msg, args...)
+	case LogLevelInfo:
+		l.logger.Info(msg, args...)
+	case LogLevelWarn:
+		l.logger.Warn(msg, args...)
+	default:
+		l.logger.Error(msg, args...)
+	}
+}
+
+// SetDebug logs the requests of the client to stderr when enabled. Use WithLogger for more control
+// over the output.
+func (c *Client) SetDebug(enabled bool) {
+	if !enabled {
+		c.logger = nil
+		return
+	}
+	c.logger = NewHCLogger(hclog.New(&hclog.LoggerOptions{
+		Name:   "hlb",
+		Level:  hclog.Debug,
+		Output: os.Stderr,
+	}))
+}
+
+func (c *Client) log(ctx context.Context, level LogLevel, msg string, fields map[string]interface{}) {
+	if c.logger == nil {
+		return
+	}
+	for k, v := range fields {
+		if isRedactedHeader(k) {
+			fields[k] = "<redacted>"
+		} else if s, ok := v.(string); ok && c.apiKey != "" && strings.Contains(s, c.apiKey) {
+			fields[k] = strings.ReplaceAll(s, c.apiKey, RedactSecret(c.apiKey))
+		}
+	}
+	c.logger.Log(ctx, level, msg, fields)
+}
+
+// logHeaders returns the headers of a request with the credentials redacted
+func logHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for k := range header {
+		switch {
+		case strings.EqualFold(k, "x-api-key"):
+			headers[k] = RedactSecret(header.Get(k))
+		case isRedactedHeader(k):
+			headers[k] = "<redacted>"
+		default:
+			headers[k] = header.Get(k)
+		}
+	}
+	return headers
+}
+
+func isRedactedHeader(name string) bool {
+	return slices.ContainsFunc(redactedHeaders, func(h string) bool {
+		return strings.EqualFold(h, name)
+	})
+}
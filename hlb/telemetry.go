@@ -0,0 +1,165 @@
+package hlb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/retry"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The client reports spans and metrics to the global OpenTelemetry providers, set with
+// otel.SetTracerProvider and otel.SetMeterProvider. Nothing is recorded until they are set.
+const instrumentationName = "gitlab.guerraz.net/HLB/hlb-terraform-provider/hlb"
+
+// Attributes of the spans and metrics of the client
+const (
+	operationKey    = attribute.Key("hlb.operation")
+	requestIDKey    = attribute.Key("hlb.request_id")
+	retryCountKey   = attribute.Key("hlb.retry_count")
+	loadBalancerKey = attribute.Key("hlb.load_balancer.id")
+	targetStatesKey = attribute.Key("hlb.target_states")
+	stateKey        = attribute.Key("hlb.state")
+	pollCountKey    = attribute.Key("hlb.poll_count")
+	outcomeKey      = attribute.Key("hlb.outcome")
+)
+
+var (
+	tracer = otel.Tracer(instrumentationName)
+	meter  = otel.Meter(instrumentationName)
+
+	requestCounter, _ = meter.Int64Counter("hlb.client.requests",
+		metric.WithDescription("Requests sent to the HLB API"),
+		metric.WithUnit("{request}"))
+	requestDuration, _ = meter.Float64Histogram("hlb.client.request.duration",
+		metric.WithDescription("Duration of the requests to the HLB API, retries included"),
+		metric.WithUnit("s"))
+	retryCounter, _ = meter.Int64Counter("hlb.client.retries",
+		metric.WithDescription("Requests to the HLB API sent again after being throttled or rejected"),
+		metric.WithUnit("{retry}"))
+	presignDuration, _ = meter.Float64Histogram("hlb.sts.presign.duration",
+		metric.WithDescription("Duration of the generation of the STS headers sent to the HLB API"),
+		metric.WithUnit("s"))
+	waitDuration, _ = meter.Float64Histogram("hlb.waiter.duration",
+		metric.WithDescription("Time spent waiting for load balancers to reach a state"),
+		metric.WithUnit("s"))
+)
+
+// requestStats counts the attempts of a request, retryablehttp only reports them to its hooks
+type requestStats struct {
+	retries atomic.Int64
+}
+
+type requestStatsKey struct{}
+
+// startRequestSpan starts the span of a request to the API
+func (c *Client) startRequestSpan(ctx context.Context, method, path string) (context.Context, trace.Span, *requestStats) {
+	operation := operationName(method, path)
+	ctx, span := tracer.Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			operationKey.String(operation),
+			semconv.HTTPRequestMethodKey.String(method),
+			semconv.URLFull(c.baseURL+path),
+			semconv.ServerAddress(c.hostname),
+		))
+	stats := &requestStats{}
+	return context.WithValue(ctx, requestStatsKey{}, stats), span, stats
+}
+
+// endRequestSpan ends the span of a request and records its metrics
+func endRequestSpan(ctx context.Context, span trace.Span, stats *requestStats, method, path string, start time.Time, resp *http.Response, err error) {
+	attrs := []attribute.KeyValue{
+		operationKey.String(operationName(method, path)),
+		semconv.HTTPRequestMethodKey.String(method),
+	}
+	retries := stats.retries.Load()
+	span.SetAttributes(retryCountKey.Int64(retries))
+
+	var apiErr *APIErrorResponse
+	switch {
+	case resp != nil:
+		attrs = append(attrs, semconv.HTTPResponseStatusCode(resp.StatusCode))
+		span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode), requestIDKey.String(resp.Header.Get(requestIDHeader)))
+	case errors.As(err, &apiErr) && apiErr.StatusCode != 0:
+		attrs = append(attrs, semconv.HTTPResponseStatusCode(apiErr.StatusCode))
+		span.SetAttributes(semconv.HTTPResponseStatusCode(apiErr.StatusCode), requestIDKey.String(apiErr.RequestID))
+	case err != nil:
+		attrs = append(attrs, semconv.ErrorTypeKey.String(fmt.Sprintf("%T", err)))
+	}
+	endSpan(span, err)
+
+	set := metric.WithAttributes(attrs...)
+	requestCounter.Add(ctx, 1, set)
+	requestDuration.Record(ctx, time.Since(start).Seconds(), set)
+	if retries > 0 {
+		retryCounter.Add(ctx, retries, set)
+	}
+}
+
+// countRetry records that a request is sent again
+func countRetry(ctx context.Context, attempt int) {
+	if stats, ok := ctx.Value(requestStatsKey{}).(*requestStats); ok {
+		stats.retries.Add(1)
+	}
+	trace.SpanFromContext(ctx).AddEvent("retry", trace.WithAttributes(attribute.Int("attempt", attempt)))
+}
+
+// operationName names a request after the API operation it calls, such as GetLoadBalancer
+func operationName(method, path string) string {
+	path, _, _ = strings.Cut(path, "?")
+	// Paths are /aws_account/{accountId}/load-balancers[/{id}[/listeners[/{id}]]]
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	resource := "LoadBalancer"
+	if len(segments) > 4 {
+		resource = "Listener"
+	}
+	collection := len(segments)%2 == 1
+
+	switch {
+	case method == http.MethodGet && collection:
+		return "List" + resource + "s"
+	case method == http.MethodGet:
+		return "Get" + resource
+	case method == http.MethodPost:
+		return "Create" + resource
+	case method == http.MethodPut:
+		return "Update" + resource
+	case method == http.MethodDelete:
+		return "Delete" + resource
+	}
+	return method + " " + path
+}
+
+// outcome is the value of the hlb.outcome attribute of an operation that returned err
+func outcome(err error) string {
+	var failedErr *LoadBalancerFailedError
+	switch {
+	case err == nil:
+		return "success"
+	case errors.As(err, &failedErr):
+		return "failed"
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, new(*retry.TimeoutError)):
+		return "timeout"
+	}
+	return "error"
+}
+
+// endSpan ends span, recording err if it is not nil
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,35 @@
+package hlb
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestOperationName(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{method: http.MethodGet, path: "/aws_account/123456789012/load-balancers", want: "ListLoadBalancers"},
+		{method: http.MethodGet, path: "/aws_account/123456789012/load-balancers?nextToken=abc", want: "ListLoadBalancers"},
+		{method: http.MethodPost, path: "/aws_account/123456789012/load-balancers", want: "CreateLoadBalancer"},
+		{method: http.MethodGet, path: "/aws_account/123456789012/load-balancers/lb-1", want: "GetLoadBalancer"},
+		{method: http.MethodPut, path: "/aws_account/123456789012/load-balancers/lb-1", want: "UpdateLoadBalancer"},
+		{method: http.MethodDelete, path: "/aws_account/123456789012/load-balancers/lb-1", want: "DeleteLoadBalancer"},
+		{method: http.MethodGet, path: "/aws_account/123456789012/load-balancers/lb-1/listeners", want: "ListListeners"},
+		{method: http.MethodPost, path: "/aws_account/123456789012/load-balancers/lb-1/listeners", want: "CreateListener"},
+		{method: http.MethodGet, path: "/aws_account/123456789012/load-balancers/lb-1/listeners/lis-1", want: "GetListener"},
+		{method: http.MethodPut, path: "/aws_account/123456789012/load-balancers/lb-1/listeners/lis-1", want: "UpdateListener"},
+		{method: http.MethodDelete, path: "/aws_account/123456789012/load-balancers/lb-1/listeners/lis-1", want: "DeleteListener"},
+		{method: http.MethodPatch, path: "/aws_account/123456789012/load-balancers/lb-1", want: "PATCH /aws_account/123456789012/load-balancers/lb-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			if got := operationName(tt.method, tt.path); got != tt.want {
+				t.Errorf("operationName(%q, %q) = %q, want %q", tt.method, tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package hlb
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Middleware wraps the transport sending the requests of a client, to observe or change requests
+// and responses. Middleware sees every attempt of a request, including retries.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc is an http.RoundTripper implemented by a function, for use in Middleware
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// WithMiddleware wraps the transport of the client with middleware. The first middleware is the
+// outermost one: it sees requests first and responses last.
+func WithMiddleware(middleware ...Middleware) ClientOption {
+	return func(c *Client) {
+		c.middleware = append(c.middleware, middleware...)
+	}
+}
+
+// WithHTTPClient sends requests with a copy of httpClient instead of the default pooled client.
+// Retries are still handled by the client.
+func WithHTTPClient(httpClient *http.Client) ClientOption {
+	return func(c *Client) {
+		hc := *httpClient
+		c.httpClient.HTTPClient = &hc
+	}
+}
+
+// WithTransport sends requests with transport instead of the default pooled transport
+func WithTransport(transport http.RoundTripper) ClientOption {
+	return func(c *Client) {
+		c.transport = transport
+	}
+}
+
+// WithProxy sends requests through the proxy at proxyURL instead of the proxy set with the
+// HTTPS_PROXY and NO_PROXY environment variables. It cannot be combined with a transport other
+// than *http.Transport.
+func WithProxy(proxyURL *url.URL) ClientOption {
+	return func(c *Client) {
+		c.proxy = proxyURL
+	}
+}
+
+// WithHeaders adds headers to every request. They cannot replace the headers carrying the
+// credentials. Authorization, Proxy-Authorization and Cookie headers are redacted from logs,
+// dry-run output and cassettes.
+func WithHeaders(headers http.Header) ClientOption {
+	return func(c *Client) {
+		if c.headers == nil {
+			c.headers = http.Header{}
+		}
+		for k, values := range headers {
+			for _, v := range values {
+				c.headers.Add(k, v)
+			}
+		}
+	}
+}
+
+// configureTransport applies WithTransport and WithProxy to the HTTP client
+func (c *Client) configureTransport() error {
+	if c.transport != nil {
+		c.httpClient.HTTPClient.Transport = c.transport
+	}
+	if c.proxy == nil {
+		return nil
+	}
+
+	t, ok := transportOrDefault(c.httpClient.HTTPClient.Transport).(*http.Transport)
+	if !ok {
+		return fmt.Errorf("a proxy cannot be set with a transport of type %T", c.httpClient.HTTPClient.Transport)
+	}
+	t = t.Clone()
+	t.Proxy = http.ProxyURL(c.proxy)
+	c.httpClient.HTTPClient.Transport = t
+	return nil
+}
+
+// applyMiddleware wraps the transport of the HTTP client with the middleware of the client
+func (c *Client) applyMiddleware() {
+	if len(c.middleware) == 0 {
+		return
+	}
+	t := transportOrDefault(c.httpClient.HTTPClient.Transport)
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		t = c.middleware[i](t)
+	}
+	c.httpClient.HTTPClient.Transport = t
+}
+
+func transportOrDefault(t http.RoundTripper) http.RoundTripper {
+	if t == nil {
+		return http.DefaultTransport
+	}
+	return t
+}
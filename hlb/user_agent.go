@@ -0,0 +1,37 @@
+package hlb
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+)
+
+// AppendUserAgentEnvVar names a suffix appended to the User-Agent sent by every client, to tell
+// apart the requests of different automations
+const AppendUserAgentEnvVar = "HLB_APPEND_USER_AGENT"
+
+// userAgentProduct identifies this package in the User-Agent
+const userAgentProduct = "hlb-go"
+
+// WithUserAgent identifies the application using the client at the start of the User-Agent, such
+// as "zonehero/1.2.0". Several products are separated by spaces.
+func WithUserAgent(product string) ClientOption {
+	return func(c *Client) {
+		c.userAgent = strings.TrimSpace(c.userAgent + " " + product)
+	}
+}
+
+// UserAgent returns the User-Agent sent by the client: the application set with WithUserAgent,
+// this package, the Go version and platform, then the suffix set with HLB_APPEND_USER_AGENT
+func (c *Client) UserAgent() string {
+	parts := []string{}
+	if c.userAgent != "" {
+		parts = append(parts, c.userAgent)
+	}
+	parts = append(parts, userAgentProduct, fmt.Sprintf("(%s; %s/%s)", runtime.Version(), runtime.GOOS, runtime.GOARCH))
+	if suffix := strings.TrimSpace(os.Getenv(AppendUserAgentEnvVar)); suffix != "" {
+		parts = append(parts, suffix)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,195 @@
+// Package telemetry exports the traces and metrics of the HLB client with the exporters selected
+// by the standard OpenTelemetry environment variables
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const (
+	exporterOTLP    = "otlp"
+	exporterConsole = "console"
+	exporterNone    = "none"
+
+	protocolGRPC = "grpc"
+
+	// FileEnvVar names the file the console exporter writes to instead of stderr. Stdout is never
+	// used: it carries the output of zonehero and the plugin protocol of the provider.
+	FileEnvVar = "HLB_TELEMETRY_FILE"
+
+	// synchronousMetricInterval is the metric export interval set by WithSynchronousExport
+	synchronousMetricInterval = 5 * time.Second
+)
+
+// Option customizes Setup
+type Option func(*settings)
+
+type settings struct {
+	synchronous bool
+}
+
+// WithSynchronousExport exports spans as they end instead of in batches, and metrics every few
+// seconds instead of every minute unless OTEL_METRIC_EXPORT_INTERVAL is set. It is meant for
+// processes that are killed rather than stopped, such as Terraform providers, which would lose
+// the telemetry waiting to be exported otherwise.
+func WithSynchronousExport() Option {
+	return func(s *settings) {
+		s.synchronous = true
+	}
+}
+
+// Setup sets the global OpenTelemetry providers from the environment:
+//   - OTEL_TRACES_EXPORTER and OTEL_METRICS_EXPORTER select otlp, console or none. They default to
+//     otlp when an OTLP endpoint is set, and to none otherwise.
+//   - OTEL_EXPORTER_OTLP_* configure the OTLP exporters, OTEL_EXPORTER_OTLP_PROTOCOL selects grpc
+//     or http/protobuf, the default.
+//   - OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES override the resource, OTEL_SDK_DISABLED
+//     disables everything.
+//
+// The returned function flushes and stops the exporters, it must be called before exiting.
+func Setup(ctx context.Context, serviceName string, opts ...Option) (func(context.Context) error, error) {
+	var s settings
+	for _, opt := range opts {
+		opt(&s)
+	}
+
+	noop := func(context.Context) error { return nil }
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") {
+		return noop, nil
+	}
+
+	tracesExporter := exporterName("OTEL_TRACES_EXPORTER", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	metricsExporter := exporterName("OTEL_METRICS_EXPORTER", "OTEL_EXPORTER_OTLP_METRICS_ENDPOINT")
+	if tracesExporter == exporterNone && metricsExporter == exporterNone {
+		return noop, nil
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		// Applied last so that the environment overrides the service name
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return noop, fmt.Errorf("failed to create telemetry resource: %w", err)
+	}
+
+	var console io.Writer = os.Stderr
+	var consoleFile *os.File
+	if path := os.Getenv(FileEnvVar); path != "" && (tracesExporter == exporterConsole || metricsExporter == exporterConsole) {
+		consoleFile, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			return noop, fmt.Errorf("failed to open telemetry file: %w", err)
+		}
+		console = consoleFile
+	}
+
+	var shutdowns []func(context.Context) error
+	shutdown := func(ctx context.Context) error {
+		var errs []error
+		for _, s := range shutdowns {
+			errs = append(errs, s(ctx))
+		}
+		if consoleFile != nil {
+			errs = append(errs, consoleFile.Close())
+		}
+		return errors.Join(errs...)
+	}
+
+	if tracesExporter != exporterNone {
+		exporter, err := newSpanExporter(ctx, tracesExporter, console)
+		if err != nil {
+			shutdown(ctx)
+			return noop, err
+		}
+		processor := sdktrace.NewBatchSpanProcessor(exporter)
+		if s.synchronous {
+			processor = sdktrace.NewSimpleSpanProcessor(exporter)
+		}
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(processor), sdktrace.WithResource(res))
+		shutdowns = append(shutdowns, tp.Shutdown)
+		otel.SetTracerProvider(tp)
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	}
+
+	if metricsExporter != exporterNone {
+		exporter, err := newMetricExporter(ctx, metricsExporter, console)
+		if err != nil {
+			shutdown(ctx)
+			return noop, err
+		}
+		var readerOpts []sdkmetric.PeriodicReaderOption
+		if s.synchronous && os.Getenv("OTEL_METRIC_EXPORT_INTERVAL") == "" {
+			readerOpts = append(readerOpts, sdkmetric.WithInterval(synchronousMetricInterval))
+		}
+		mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter, readerOpts...)), sdkmetric.WithResource(res))
+		shutdowns = append(shutdowns, mp.Shutdown)
+		otel.SetMeterProvider(mp)
+	}
+
+	return shutdown, nil
+}
+
+// exporterName returns the exporter selected with the variable name
+func exporterName(name, signalEndpoint string) string {
+	if exporter := strings.ToLower(strings.TrimSpace(os.Getenv(name))); exporter != "" {
+		return exporter
+	}
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv(signalEndpoint) != "" {
+		return exporterOTLP
+	}
+	return exporterNone
+}
+
+// otlpProtocol returns the protocol selected for the OTLP exporter of a signal
+func otlpProtocol(signalProtocol string) string {
+	if protocol := os.Getenv(signalProtocol); protocol != "" {
+		return protocol
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+}
+
+func newSpanExporter(ctx context.Context, name string, console io.Writer) (sdktrace.SpanExporter, error) {
+	switch name {
+	case exporterOTLP:
+		if otlpProtocol("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL") == protocolGRPC {
+			return otlptracegrpc.New(ctx)
+		}
+		return otlptracehttp.New(ctx)
+	case exporterConsole:
+		return stdouttrace.New(stdouttrace.WithWriter(console))
+	}
+	return nil, fmt.Errorf("unsupported OTEL_TRACES_EXPORTER %q, must be otlp, console or none", name)
+}
+
+func newMetricExporter(ctx context.Context, name string, console io.Writer) (sdkmetric.Exporter, error) {
+	switch name {
+	case exporterOTLP:
+		if otlpProtocol("OTEL_EXPORTER_OTLP_METRICS_PROTOCOL") == protocolGRPC {
+			return otlpmetricgrpc.New(ctx)
+		}
+		return otlpmetrichttp.New(ctx)
+	case exporterConsole:
+		return stdoutmetric.New(stdoutmetric.WithWriter(console))
+	}
+	return nil, fmt.Errorf("unsupported OTEL_METRICS_EXPORTER %q, must be otlp, console or none", name)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+
+	"github.com/hashicorp/terraform-plugin-log/tflog"
+	"gitlab.guerraz.net/HLB/hlb-terraform-provider/hlb"
+)
+
+// logSubsystem is the tflog subsystem of the HLB API client. Its level follows TF_LOG and
+// TF_LOG_PROVIDER, and can be set on its own with TF_LOG_PROVIDER_HLB_CLIENT.
+const logSubsystem = "hlb"
+
+// tflogLogger writes the log messages of the HLB client to the provider logs, so that they never
+// reach stdout, which Terraform uses to talk to the provider
+type tflogLogger struct{}
+
+func (tflogLogger) Log(ctx context.Context, level hlb.LogLevel, msg string, fields map[string]interface{}) {
+	ctx = tflog.NewSubsystem(ctx, logSubsystem, tflog.WithLevelFromEnv("TF_LOG_PROVIDER_HLB", "CLIENT"))
+	ctx = tflog.SubsystemMaskFieldValuesWithFieldKeys(ctx, logSubsystem, "x-api-key", "X-Sts-Gci-Headers")
+
+	switch level {
+	case hlb.LogLevelTrace:
+		tflog.SubsystemTrace(ctx, logSubsystem, msg, fields)
+	case hlb.LogLevelDebug:
+		tflog.SubsystemDebug(ctx, logSubsystem, msg, fields)
+	case hlb.LogLevelInfo:
+		tflog.SubsystemInfo(ctx, logSubsystem, msg, fields)
+	case hlb.LogLevelWarn:
+		tflog.SubsystemWarn(ctx, logSubsystem, msg, fields)
+	default:
+		tflog.SubsystemError(ctx, logSubsystem, msg, fields)
+	}
+}
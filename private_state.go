@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+)
+
+// privateETagKey is the private state key holding the ETag of the version of a resource last read.
+// Updates are only applied to this version, so that changes made outside Terraform since the last
+// refresh are not overwritten.
+const privateETagKey = "etag"
+
+// privateState is the private state of a resource in a response
+type privateState interface {
+	SetKey(ctx context.Context, key string, value []byte) diag.Diagnostics
+}
+
+// privateStateReader is the private state of a resource in a request
+type privateStateReader interface {
+	GetKey(ctx context.Context, key string) ([]byte, diag.Diagnostics)
+}
+
+// setETag stores the ETag of the version of a resource last read in its private state
+func setETag(ctx context.Context, private privateState, etag string) diag.Diagnostics {
+	value, err := json.Marshal(etag)
+	if err != nil {
+		var diags diag.Diagnostics
+		diags.AddError("Error storing ETag", err.Error())
+		return diags
+	}
+	return private.SetKey(ctx, privateETagKey, value)
+}
+
+// getETag returns the ETag stored in the private state of a resource, empty for resources read
+// before ETags were stored
+func getETag(ctx context.Context, private privateStateReader) (string, diag.Diagnostics) {
+	value, diags := private.GetKey(ctx, privateETagKey)
+	if diags.HasError() || len(value) == 0 {
+		return "", diags
+	}
+
+	var etag string
+	if err := json.Unmarshal(value, &etag); err != nil {
+		diags.AddError("Error reading ETag", err.Error())
+	}
+	return etag, diags
+}
+
+// privateImportedKey marks a resource that was just imported. Its first read also refreshes the
+// optional attributes that are otherwise only refreshed when the configuration sets them.
+const privateImportedKey = "imported"
+
+// setImported marks a resource as just imported, or clears the mark once it has been read
+func setImported(ctx context.Context, private privateState, imported bool) diag.Diagnostics {
+	var value []byte
+	if imported {
+		value = []byte("true")
+	}
+	return private.SetKey(ctx, privateImportedKey, value)
+}
+
+// isImported reports whether a resource was just imported
+func isImported(ctx context.Context, private privateStateReader) (bool, diag.Diagnostics) {
+	value, diags := private.GetKey(ctx, privateImportedKey)
+	return string(value) == "true", diags
+}
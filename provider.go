@@ -3,31 +3,57 @@ package main
 import (
 	"context"
 	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
 
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/hashicorp/terraform-plugin-framework/datasource"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/provider"
 	"github.com/hashicorp/terraform-plugin-framework/provider/schema"
 	"github.com/hashicorp/terraform-plugin-framework/providerserver"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/types"
 	"gitlab.guerraz.net/HLB/hlb-terraform-provider/hlb"
+	"gitlab.guerraz.net/HLB/hlb-terraform-provider/internal/telemetry"
 )
 
 // Ensure the implementation satisfies the provider.Provider interface.
 var _ provider.Provider = &HLBProvider{}
 
+// version is set by goreleaser
+var version = "dev"
+
+// credentialsRefreshMarginEnvVar sets credentials_refresh_margin when the provider configuration
+// does not
+const credentialsRefreshMarginEnvVar = "HLB_CREDENTIALS_REFRESH_MARGIN"
+
 func main() {
+	ctx := context.Background()
+	// Stdout carries the plugin protocol, errors can only be logged. Terraform usually kills the
+	// provider before Serve returns, so telemetry is exported as it is produced rather than
+	// flushed by the deferred shutdown.
+	shutdownTelemetry, err := telemetry.Setup(ctx, "terraform-provider-hlb", telemetry.WithSynchronousExport())
+	if err != nil {
+		log.Printf("[WARN] Telemetry disabled: %v", err)
+	}
+	defer shutdownTelemetry(ctx)
+
 	opts := providerserver.ServeOpts{
 		Address: "registry.terraform.io/OrographicLift/hlb",
 	}
-	providerserver.Serve(context.Background(), func() provider.Provider {
-		return &HLBProvider{}
+	providerserver.Serve(ctx, func() provider.Provider {
+		return &HLBProvider{version: version}
 	}, opts)
 }
 
 // HLBProvider defines the provider implementation.
-type HLBProvider struct{}
+type HLBProvider struct {
+	// version is the version of the provider, dev when not built by goreleaser
+	version string
+}
 
 // HLBProviderModel describes the provider data model.
 type HLBProviderModel struct {
@@ -35,10 +61,13 @@ type HLBProviderModel struct {
 	AWSRegion  types.String `tfsdk:"aws_region"`
 	AWSProfile types.String `tfsdk:"aws_profile"`
 	Partition  types.String `tfsdk:"partition"`
+
+	CredentialsRefreshMargin types.String `tfsdk:"credentials_refresh_margin"`
 }
 
 func (p *HLBProvider) Metadata(_ context.Context, _ provider.MetadataRequest, resp *provider.MetadataResponse) {
 	resp.TypeName = "hlb"
+	resp.Version = p.version
 }
 
 func (p *HLBProvider) Schema(_ context.Context, _ provider.SchemaRequest, resp *provider.SchemaResponse) {
@@ -62,6 +91,10 @@ func (p *HLBProvider) Schema(_ context.Context, _ provider.SchemaRequest, resp *
 				Description: "AWS partition to use. Defaults to 'aws'.",
 				Optional:    true,
 			},
+			"credentials_refresh_margin": schema.StringAttribute{
+				Description: "How long before their expiry the STS headers sent to the API are regenerated, as a duration such as '2m'. Defaults to '1m'. Can also be set with the HLB_CREDENTIALS_REFRESH_MARGIN environment variable.",
+				Optional:    true,
+			},
 		},
 	}
 }
@@ -79,6 +112,28 @@ func (p *HLBProvider) Configure(ctx context.Context, req provider.ConfigureReque
 		config.Partition = types.StringValue("aws")
 	}
 
+	clientOpts := []hlb.ClientOption{
+		hlb.WithLogger(tflogLogger{}),
+		hlb.WithCassetteFromEnv(),
+		hlb.WithUserAgent(p.userAgent(req.TerraformVersion)),
+	}
+	margin := config.CredentialsRefreshMargin.ValueString()
+	if config.CredentialsRefreshMargin.IsNull() {
+		margin = os.Getenv(credentialsRefreshMarginEnvVar)
+	}
+	if margin != "" {
+		d, err := time.ParseDuration(margin)
+		if err != nil || d < 0 {
+			resp.Diagnostics.AddAttributeError(
+				path.Root("credentials_refresh_margin"),
+				"Invalid Credentials Refresh Margin",
+				fmt.Sprintf("%q is not a valid duration, such as '2m'.", margin),
+			)
+			return
+		}
+		clientOpts = append(clientOpts, hlb.WithCredentialsRefreshMargin(d))
+	}
+
 	// Configure AWS SDK
 	var awsOpts []func(*awsconfig.LoadOptions) error
 	if !config.AWSProfile.IsNull() {
@@ -98,7 +153,7 @@ func (p *HLBProvider) Configure(ctx context.Context, req provider.ConfigureReque
 	}
 
 	// Create HLB client
-	client, err := hlb.NewClient(ctx, config.APIKey.ValueString(), awsCfg, config.Partition.ValueString())
+	client, err := hlb.NewClient(ctx, config.APIKey.ValueString(), awsCfg, config.Partition.ValueString(), clientOpts...)
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Unable to Create HLB Client",
@@ -111,6 +166,19 @@ func (p *HLBProvider) Configure(ctx context.Context, req provider.ConfigureReque
 	resp.ResourceData = client
 }
 
+// userAgent identifies the provider and Terraform in the requests to the API. TF_APPEND_USER_AGENT
+// is appended like in the other providers.
+func (p *HLBProvider) userAgent(terraformVersion string) string {
+	ua := fmt.Sprintf("terraform-provider-hlb/%s", p.version)
+	if terraformVersion != "" {
+		ua += fmt.Sprintf(" terraform/%s", terraformVersion)
+	}
+	if suffix := strings.TrimSpace(os.Getenv("TF_APPEND_USER_AGENT")); suffix != "" {
+		ua += " " + suffix
+	}
+	return ua
+}
+
 func (p *HLBProvider) Resources(_ context.Context) []func() resource.Resource {
 	return []func() resource.Resource{
 		NewLoadBalancerResource,
@@ -3,10 +3,12 @@ package main
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/hashicorp/terraform-plugin-framework-validators/float64validator"
 	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
 	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
+	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema/booldefault"
@@ -20,8 +22,9 @@ import (
 
 // Ensure the implementation satisfies the expected interfaces
 var (
-	_ resource.Resource              = &listenerAttachmentResource{}
-	_ resource.ResourceWithConfigure = &listenerAttachmentResource{}
+	_ resource.Resource                = &listenerAttachmentResource{}
+	_ resource.ResourceWithConfigure   = &listenerAttachmentResource{}
+	_ resource.ResourceWithImportState = &listenerAttachmentResource{}
 )
 
 // NewListenerAttachmentResource is a helper function to simplify the provider implementation.
@@ -204,6 +207,8 @@ func (r *listenerAttachmentResource) Create(ctx context.Context, req resource.Cr
 		input.CertificateSecretsName = plan.CertificateSecretsName.ValueString()
 	}
 
+	// The client sends a client token with the request, so that its retries create a single
+	// listener when the response to the first attempt is lost
 	listener, err := r.client.CreateListener(ctx, plan.LoadBalancerID.ValueString(), input)
 	if err != nil {
 		resp.Diagnostics.AddError(
@@ -215,6 +220,7 @@ func (r *listenerAttachmentResource) Create(ctx context.Context, req resource.Cr
 
 	// Map response body to schema and populate Computed attribute values
 	plan.ID = types.StringValue(listener.ID)
+	resp.Diagnostics.Append(setETag(ctx, resp.Private, listener.ETag())...)
 
 	// Set state to fully populated data
 	diags = resp.State.Set(ctx, plan)
@@ -242,6 +248,7 @@ func (r *listenerAttachmentResource) Read(ctx context.Context, req resource.Read
 		)
 		return
 	}
+	resp.Diagnostics.Append(setETag(ctx, resp.Private, listener.ETag())...)
 
 	// Overwrite items with refreshed state
 	if listener.ALPNPolicy == "" {
@@ -323,8 +330,22 @@ func (r *listenerAttachmentResource) Update(ctx context.Context, req resource.Up
 		input.TargetGroupARN = &v
 	}
 
+	// Only update the version of the listener last read
+	input.IfMatch, diags = getETag(ctx, req.Private)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
 	// Update existing listener
 	_, err := r.client.UpdateListener(ctx, state.LoadBalancerID.ValueString(), state.ID.ValueString(), input)
+	if hlb.IsConflict(err) {
+		resp.Diagnostics.AddError(
+			"HLB Listener Changed Outside Terraform",
+			fmt.Sprintf("Listener %s was changed outside Terraform since it was last read, the update was not applied so as not to overwrite these changes. Refresh the state, review the plan and retry: %v", state.ID.ValueString(), err),
+		)
+		return
+	}
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Error Updating HLB Listener",
@@ -345,6 +366,7 @@ func (r *listenerAttachmentResource) Update(ctx context.Context, req resource.Up
 
 	// Update resource state
 	plan.ID = types.StringValue(listener.ID)
+	resp.Diagnostics.Append(setETag(ctx, resp.Private, listener.ETag())...)
 
 	// Set state to fully populated data
 	diags = resp.State.Set(ctx, plan)
@@ -373,3 +395,18 @@ func (r *listenerAttachmentResource) Delete(ctx context.Context, req resource.De
 		return
 	}
 }
+
+func (r *listenerAttachmentResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
+	// Listeners are addressed through their load balancer, so the import ID carries both
+	loadBalancerID, listenerID, ok := strings.Cut(req.ID, "/")
+	if !ok || loadBalancerID == "" || listenerID == "" {
+		resp.Diagnostics.AddError(
+			"Unexpected Import Identifier",
+			fmt.Sprintf("Expected import identifier with format: load_balancer_id/listener_id. Got: %q", req.ID),
+		)
+		return
+	}
+
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("load_balancer_id"), loadBalancerID)...)
+	resp.Diagnostics.Append(resp.State.SetAttribute(ctx, path.Root("id"), listenerID)...)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"regexp"
 	"strings"
@@ -9,6 +10,7 @@ import (
 	"github.com/hashicorp/terraform-plugin-framework-validators/int64validator"
 	"github.com/hashicorp/terraform-plugin-framework-validators/stringvalidator"
 
+	"github.com/hashicorp/terraform-plugin-framework/diag"
 	"github.com/hashicorp/terraform-plugin-framework/path"
 	"github.com/hashicorp/terraform-plugin-framework/resource"
 	"github.com/hashicorp/terraform-plugin-framework/resource/schema"
@@ -521,8 +523,25 @@ func (r *loadBalancerResource) Create(ctx context.Context, req resource.CreateRe
 		input.Tags = tags
 	}
 
-	// Create new load balancer
+	// Create new load balancer. The client sends a client token with the request, so that its
+	// retries create a single load balancer when the response to the first attempt is lost.
 	lb, err := r.client.CreateLoadBalancer(ctx, input)
+	var incompleteErr *hlb.IncompleteCreateError
+	if errors.As(err, &incompleteErr) {
+		// Terraform taints the load balancer saved with the error, the next apply replaces it
+		// instead of creating another one next to it
+		plan.ID = types.StringValue(incompleteErr.ID)
+		plan.DNSName = types.StringNull()
+		plan.State = types.StringNull()
+		resp.Diagnostics.Append(resp.State.Set(ctx, plan)...)
+		resp.Diagnostics.AddError(
+			"Error creating load balancer",
+			fmt.Sprintf("Load balancer %s was created but did not become active: %v\n\n"+
+				"It was saved in the state as tainted, the next apply replaces it. Run terraform untaint to keep it instead.",
+				incompleteErr.ID, incompleteErr.Err),
+		)
+		return
+	}
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Error creating load balancer",
@@ -535,6 +554,7 @@ func (r *loadBalancerResource) Create(ctx context.Context, req resource.CreateRe
 	plan.ID = types.StringValue(lb.ID)
 	plan.DNSName = types.StringValue(lb.DNSName)
 	plan.State = types.StringValue(lb.State)
+	resp.Diagnostics.Append(setETag(ctx, resp.Private, lb.ETag())...)
 
 	// Set state to fully populated data
 	diags = resp.State.Set(ctx, plan)
@@ -562,6 +582,7 @@ func (r *loadBalancerResource) Read(ctx context.Context, req resource.ReadReques
 		)
 		return
 	}
+	resp.Diagnostics.Append(setETag(ctx, resp.Private, lb.ETag())...)
 
 	// Overwrite items with refreshed state
 	state.ClientKeepAlive = types.Int64Value(int64(lb.ClientKeepAlive))
@@ -586,6 +607,26 @@ func (r *loadBalancerResource) Read(ctx context.Context, req resource.ReadReques
 	state.ZoneID = types.StringValue(lb.ZoneID)
 	state.ZoneName = types.StringValue(lb.ZoneName)
 
+	// Refresh subnets, security groups and tags so changes made outside Terraform and
+	// imported load balancers are reflected in state
+	imported, diags := isImported(ctx, req.Private)
+	resp.Diagnostics.Append(diags...)
+	subnets, diags := types.SetValueFrom(ctx, types.StringType, lb.Subnets)
+	resp.Diagnostics.Append(diags...)
+	securityGroups, diags := refreshedStringSet(ctx, state.SecurityGroups, lb.SecurityGroups, imported)
+	resp.Diagnostics.Append(diags...)
+	tags, diags := refreshedStringMap(ctx, state.Tags, lb.Tags, imported)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+	state.Subnets = subnets
+	state.SecurityGroups = securityGroups
+	state.Tags = tags
+	if imported {
+		resp.Diagnostics.Append(setImported(ctx, resp.Private, false)...)
+	}
+
 	// Handle AccessLogs - set to null if not present in API response
 	if lb.AccessLogs != nil {
 		state.AccessLogs = &accessLogsModel{
@@ -702,8 +743,47 @@ func (r *loadBalancerResource) Update(ctx context.Context, req resource.UpdateRe
 		input.LaunchConfig = launchConfigToAPI(plan.LaunchConfig)
 	}
 
+	// Security groups and tags are refreshed on read, so send them whenever they change
+	if !plan.SecurityGroups.Equal(state.SecurityGroups) {
+		securityGroups := []string{}
+		if !plan.SecurityGroups.IsNull() {
+			diags = plan.SecurityGroups.ElementsAs(ctx, &securityGroups, false)
+			resp.Diagnostics.Append(diags...)
+			if resp.Diagnostics.HasError() {
+				return
+			}
+		}
+		input.SecurityGroups = securityGroups
+	}
+
+	if !plan.Tags.Equal(state.Tags) {
+		tags := map[string]string{}
+		if !plan.Tags.IsNull() {
+			diags = plan.Tags.ElementsAs(ctx, &tags, false)
+			resp.Diagnostics.Append(diags...)
+			if resp.Diagnostics.HasError() {
+				return
+			}
+		}
+		input.Tags = &tags
+	}
+
+	// Only update the version of the load balancer last read
+	input.IfMatch, diags = getETag(ctx, req.Private)
+	resp.Diagnostics.Append(diags...)
+	if resp.Diagnostics.HasError() {
+		return
+	}
+
 	// Update existing load balancer
 	_, err := r.client.UpdateLoadBalancer(ctx, state.ID.ValueString(), input)
+	if hlb.IsConflict(err) {
+		resp.Diagnostics.AddError(
+			"HLB Load Balancer Changed Outside Terraform",
+			fmt.Sprintf("Load balancer %s was changed outside Terraform since it was last read, the update was not applied so as not to overwrite these changes. Refresh the state, review the plan and retry: %v", state.ID.ValueString(), err),
+		)
+		return
+	}
 	if err != nil {
 		resp.Diagnostics.AddError(
 			"Error Updating HLB Load Balancer",
@@ -726,6 +806,7 @@ func (r *loadBalancerResource) Update(ctx context.Context, req resource.UpdateRe
 	plan.ID = types.StringValue(lb.ID)
 	plan.DNSName = types.StringValue(lb.DNSName)
 	plan.State = types.StringValue(lb.State)
+	resp.Diagnostics.Append(setETag(ctx, resp.Private, lb.ETag())...)
 
 	// Set state to fully populated data
 	diags = resp.State.Set(ctx, plan)
@@ -758,6 +839,32 @@ func (r *loadBalancerResource) Delete(ctx context.Context, req resource.DeleteRe
 func (r *loadBalancerResource) ImportState(ctx context.Context, req resource.ImportStateRequest, resp *resource.ImportStateResponse) {
 	// Retrieve import ID and save to id attribute
 	resource.ImportStatePassthroughID(ctx, path.Root("id"), req, resp)
+	resp.Diagnostics.Append(setImported(ctx, resp.Private, true)...)
+}
+
+// refreshedStringSet returns the value of an optional set attribute refreshed to live. The
+// attribute stays null when the configuration does not set it, so that values set outside
+// Terraform are not reported as drift, unless the load balancer is being imported.
+func refreshedStringSet(ctx context.Context, current types.Set, live []string, imported bool) (types.Set, diag.Diagnostics) {
+	if current.IsNull() && (!imported || len(live) == 0) {
+		return current, nil
+	}
+	if live == nil {
+		live = []string{}
+	}
+	return types.SetValueFrom(ctx, types.StringType, live)
+}
+
+// refreshedStringMap returns the value of an optional map attribute refreshed to live, like
+// refreshedStringSet
+func refreshedStringMap(ctx context.Context, current types.Map, live map[string]string, imported bool) (types.Map, diag.Diagnostics) {
+	if current.IsNull() && (!imported || len(live) == 0) {
+		return current, nil
+	}
+	if live == nil {
+		live = map[string]string{}
+	}
+	return types.MapValueFrom(ctx, types.StringType, live)
 }
 
 // accessLogsToAPI converts an accessLogsModel to an API AccessLogs object
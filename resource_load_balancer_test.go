@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-framework/attr"
+	"github.com/hashicorp/terraform-plugin-framework/diag"
+	"github.com/hashicorp/terraform-plugin-framework/types"
+)
+
+func TestRefreshedStringMap(t *testing.T) {
+	ctx := context.Background()
+	configured := types.MapValueMust(types.StringType, map[string]attr.Value{"env": types.StringValue("prod")})
+
+	tests := []struct {
+		name     string
+		current  types.Map
+		live     map[string]string
+		imported bool
+		want     types.Map
+	}{
+		{
+			name:    "tags added outside Terraform are not managed",
+			current: types.MapNull(types.StringType),
+			live:    map[string]string{"owner": "ops"},
+			want:    types.MapNull(types.StringType),
+		},
+		{
+			name:    "changes to managed tags are refreshed",
+			current: configured,
+			live:    map[string]string{"env": "dev", "owner": "ops"},
+			want:    types.MapValueMust(types.StringType, map[string]attr.Value{"env": types.StringValue("dev"), "owner": types.StringValue("ops")}),
+		},
+		{
+			name:     "imported tags are refreshed",
+			current:  types.MapNull(types.StringType),
+			live:     map[string]string{"owner": "ops"},
+			imported: true,
+			want:     types.MapValueMust(types.StringType, map[string]attr.Value{"owner": types.StringValue("ops")}),
+		},
+		{
+			name:     "imported load balancers without tags",
+			current:  types.MapNull(types.StringType),
+			imported: true,
+			want:     types.MapNull(types.StringType),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, diags := refreshedStringMap(ctx, tt.current, tt.live, tt.imported)
+			if diags.HasError() {
+				t.Fatalf("refreshedStringMap() diagnostics = %v", diags)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("refreshedStringMap() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefreshedStringSet(t *testing.T) {
+	ctx := context.Background()
+	configured := types.SetValueMust(types.StringType, []attr.Value{types.StringValue("sg-1")})
+
+	tests := []struct {
+		name     string
+		current  types.Set
+		live     []string
+		imported bool
+		want     types.Set
+	}{
+		{
+			name:    "security groups added outside Terraform are not managed",
+			current: types.SetNull(types.StringType),
+			live:    []string{"sg-2"},
+			want:    types.SetNull(types.StringType),
+		},
+		{
+			name:    "changes to managed security groups are refreshed",
+			current: configured,
+			live:    []string{"sg-1", "sg-2"},
+			want:    types.SetValueMust(types.StringType, []attr.Value{types.StringValue("sg-1"), types.StringValue("sg-2")}),
+		},
+		{
+			name:    "removed security groups are refreshed",
+			current: configured,
+			want:    types.SetValueMust(types.StringType, []attr.Value{}),
+		},
+		{
+			name:     "imported security groups are refreshed",
+			current:  types.SetNull(types.StringType),
+			live:     []string{"sg-2"},
+			imported: true,
+			want:     types.SetValueMust(types.StringType, []attr.Value{types.StringValue("sg-2")}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, diags := refreshedStringSet(ctx, tt.current, tt.live, tt.imported)
+			if diags.HasError() {
+				t.Fatalf("refreshedStringSet() diagnostics = %v", diags)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("refreshedStringSet() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// testPrivateState stores private state keys like the framework, setting an empty value removes it
+type testPrivateState map[string][]byte
+
+func (s testPrivateState) SetKey(_ context.Context, key string, value []byte) diag.Diagnostics {
+	if len(value) == 0 {
+		delete(s, key)
+	} else {
+		s[key] = value
+	}
+	return nil
+}
+
+func (s testPrivateState) GetKey(_ context.Context, key string) ([]byte, diag.Diagnostics) {
+	return s[key], nil
+}
+
+func TestImportedMark(t *testing.T) {
+	ctx := context.Background()
+	private := testPrivateState{}
+
+	for _, step := range []struct {
+		imported bool
+	}{{imported: true}, {imported: false}} {
+		setImported(ctx, private, step.imported)
+		if got, _ := isImported(ctx, private); got != step.imported {
+			t.Errorf("isImported() after setImported(%t) = %t", step.imported, got)
+		}
+	}
+	if len(private) != 0 {
+		t.Errorf("private state = %v after the mark was cleared, want it empty", private)
+	}
+}